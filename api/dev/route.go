@@ -2,12 +2,25 @@ package dev
 
 import (
 	"github.com/gin-gonic/gin"
+	"volo_meeting/lib/callback"
+	"volo_meeting/lib/ice"
+	"volo_meeting/lib/ws"
 )
 
 func InitApi(group *gin.RouterGroup) {
 	group.GET("ping", Pong)
+	group.GET("ws", WSStats)
+	group.GET("ice", ICEStats)
 }
 
 func Pong(ctx *gin.Context) {
 
 }
+
+func WSStats(ctx *gin.Context) {
+	callback.Success(ctx, ws.GetStats())
+}
+
+func ICEStats(ctx *gin.Context) {
+	callback.Success(ctx, ice.GetStats())
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"volo_meeting/consts"
+	"volo_meeting/internal/usecase/ice/request"
+	"volo_meeting/internal/usecase/ice/service"
+	"volo_meeting/lib/callback"
+	error2 "volo_meeting/lib/error"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetIceServers descp: the iceServers for RTCPeerConnection, with TURN credentials expiring in turn.ttl
+func GetIceServers(ctx *gin.Context) {
+	query := &request.IceQuery{}
+	if err := ctx.ShouldBindQuery(query); err != nil {
+		callback.Error(ctx, error2.New(consts.ParamError, err))
+		return
+	}
+
+	service.GetIceServers(ctx, query)
+}
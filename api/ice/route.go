@@ -0,0 +1,10 @@
+package ice
+
+import (
+	"github.com/gin-gonic/gin"
+	"volo_meeting/api/ice/handler"
+)
+
+func InitApi(group *gin.RouterGroup) {
+	group.GET("servers", handler.GetIceServers)
+}
@@ -7,6 +7,7 @@ import (
 	"go.uber.org/zap"
 	"time"
 	"volo_meeting/api/dev"
+	"volo_meeting/api/ice"
 	"volo_meeting/api/meeting"
 	"volo_meeting/lib/auth"
 )
@@ -23,6 +24,7 @@ func Init() *gin.Engine {
 		v1 := api.Group("v1", auth.Debug)
 		{
 			meeting.InitApi(v1.Group("meeting"))
+			ice.InitApi(v1.Group("ice"))
 		}
 	}
 
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"volo_meeting/consts"
+	"volo_meeting/internal/usecase/meeting/request"
+	"volo_meeting/internal/usecase/meeting/service"
+	"volo_meeting/lib/callback"
+	error2 "volo_meeting/lib/error"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetChatHistory(ctx *gin.Context) {
+	query := &request.ChatQuery{}
+	if err := ctx.ShouldBindQuery(query); err != nil {
+		callback.Error(ctx, error2.New(consts.ParamError, err))
+		return
+	}
+
+	service.GetChatHistory(ctx, query)
+}
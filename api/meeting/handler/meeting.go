@@ -1,10 +1,10 @@
 package handler
 
 import (
-	"errors"
 	"time"
 	"volo_meeting/consts"
 	"volo_meeting/internal/hub"
+	"volo_meeting/internal/usecase/meeting/request"
 	"volo_meeting/internal/usecase/meeting/service"
 	"volo_meeting/lib/callback"
 	error2 "volo_meeting/lib/error"
@@ -13,8 +13,38 @@ import (
 )
 
 func AddMeeting(ctx *gin.Context) {
+	option := &request.MeetingOption{}
+	if err := ctx.ShouldBindQuery(option); err != nil {
+		callback.Error(ctx, error2.New(consts.ParamError, err))
+		return
+	}
+
+	callback.Final(ctx, func() (any, error) {
+		return service.NewMeeting(option)
+	})
+}
+
+func CreateMeeting(ctx *gin.Context) {
+	option := &request.ScheduleOption{}
+	if err := ctx.ShouldBindJSON(option); err != nil {
+		callback.Error(ctx, error2.New(consts.ParamError, err))
+		return
+	}
+
+	callback.Final(ctx, func() (any, error) {
+		return service.ScheduleMeeting(option)
+	})
+}
+
+func CreateSeries(ctx *gin.Context) {
+	option := &request.SeriesOption{}
+	if err := ctx.ShouldBindJSON(option); err != nil {
+		callback.Error(ctx, error2.New(consts.ParamError, err))
+		return
+	}
+
 	callback.Final(ctx, func() (any, error) {
-		return service.NewMeeting()
+		return service.NewSeries(option)
 	})
 }
 
@@ -31,17 +61,16 @@ func GetMemberList(ctx *gin.Context) {
 }
 
 func JoinMeetingRoom(ctx *gin.Context) {
-	meetingId := ctx.Query("meeting_id")
-	deviceId := ctx.Query("id")
-	nickname := ctx.Query("nickname")
-	if len(meetingId) == 0 || len(deviceId) == 0 || len(nickname) == 0 {
-		callback.Error(ctx, error2.New(consts.ParamError, errors.New("empty params")))
+	option := &request.JoinOption{}
+	if err := ctx.ShouldBindQuery(option); err != nil {
+		callback.Error(ctx, error2.New(consts.ParamError, err))
 		return
 	}
 
-	service.JoinMeetingRoom(ctx, meetingId, &hub.Device{
-		Id:       deviceId,
-		Nickname: nickname,
-		JoinTime: time.Now().Unix(),
+	service.JoinMeetingRoom(ctx, option, &hub.Device{
+		Id:        option.Id,
+		Nickname:  option.Nickname,
+		JoinTime:  time.Now().Unix(),
+		RelayOnly: option.RelayOnly,
 	})
 }
@@ -7,6 +7,9 @@ import (
 
 func InitApi(group *gin.RouterGroup) {
 	group.GET("fast", handler.AddMeeting)
+	group.POST("", handler.CreateMeeting)
+	group.POST("series", handler.CreateSeries)
 	// group.GET("member", handler.GetMemberList)
 	group.GET("room", handler.JoinMeetingRoom)
+	group.GET("chat", handler.GetChatHistory)
 }
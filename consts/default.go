@@ -12,7 +12,41 @@ const (
 	DefaultMeetingIdSize  = 21
 	FriendlyIdReader      = "0123456789"
 	DefaultFriendlyIdSize = 8
-	KeepaliveInterval     = 20 * time.Second
+	DefaultPingInterval   = 10 * time.Second
+	DefaultPongWait       = 20 * time.Second
+	DefaultWriteWait      = 10 * time.Second
+	MaxPasscodeFailures   = 5
+	PasscodeLockout       = 15 * time.Minute
+	ChatHistorySize       = 50
+	AttendanceExpire      = 24 * 30 * time.Hour
+	MaxChatLength         = 2000
+	MaxBreakoutName       = 64 // descp runes, as the breakout column of chat
+	DefaultFloorLimit     = 1
+	DefaultReconnectGrace = 30 * time.Second
+	DefaultOutboxSize     = 256
+	AckTimeout            = 3 * time.Second
+	MaxRetransmit         = 5
+	DefaultSendQueueSize  = 64
+	DefaultIdleTimeout    = 5 * time.Minute
+	DefaultUnusedExpire   = 24 * time.Hour
+	MeetingSweepInterval  = time.Minute
+	DefaultOvertime       = 10 * time.Minute
+	OvertimeWarnInterval  = time.Minute
+	DefaultTurnTTL        = 24 * time.Hour
+	TurnRefreshAhead      = 5 * time.Minute
+	OfferTimeout          = 30 * time.Second
+)
+
+// descp cluster
+const (
+	DefaultClusterHeartbeat = 5 * time.Second
+	PresenceTTLFactor       = 3
+)
+
+// descp cluster.mode
+const (
+	FanoutMode   = "fanout"   // descp members of a room spread over nodes, messages are relayed by redis pub/sub
+	AffinityMode = "affinity" // descp members of a room are redirected to the node owning it
 )
 
 // descp immutable constants
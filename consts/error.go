@@ -15,6 +15,10 @@ const (
 	MarshalError
 	WSError
 	MeetingError
+	NotOpenedError
+	MissedError
+	EndedSeriesError
+	SdpError
 )
 
 type ErrorType string
@@ -31,6 +35,10 @@ var Code2Type = map[ErrorCode]ErrorType{
 	MarshalError:     "Marshal Error",
 	WSError:          "WS Error",
 	MeetingError:     "Meeting Error",
+	NotOpenedError:   "Not Opened Error",
+	MissedError:      "Missed Error",
+	EndedSeriesError: "Ended Series Error",
+	SdpError:         "SDP Error",
 }
 
 var Code2HttpStatus = map[ErrorCode]int{
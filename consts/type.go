@@ -6,17 +6,75 @@ const (
 	WrongMessageModel ErrorId = -1
 	WrongMeeting      ErrorId = -2
 	InvalidId         ErrorId = -3
+	DeniedByHost      ErrorId = -4
+	FullRoom          ErrorId = -5
+	LockedRoom        ErrorId = -6
+	TakenId           ErrorId = -7
 )
 
 type Event string
 
 const (
-	Description Event = "description"
-	Candidate   Event = "iceCandidate"
-	Device      Event = "device"
-	Member      Event = "member"
-	Leave       Event = "leave"
-	Error       Event = "error"
+	Description  Event = "description"
+	Candidate    Event = "iceCandidate"
+	Device       Event = "device"
+	Member       Event = "member"
+	Leave        Event = "leave"
+	Error        Event = "error"
+	Session      Event = "session"
+	Reconnecting Event = "reconnecting"
+	Resumed      Event = "resumed"
+	Ping         Event = "ping"
+	Pong         Event = "pong"
+	AckMode      Event = "ackMode"
+	Ack          Event = "ack"
+	Chat         Event = "chat"
+	Whisper      Event = "whisper"
+	Unreachable  Event = "unreachable" // descp reply to sender with the unknown target device ids
+	Media        Event = "media"
+
+	// descp screen-share floor events, Floor lists the holders to all devices in room
+	Floor        Event = "floor"
+	FloorRequest Event = "floorRequest"
+	FloorRelease Event = "floorRelease"
+	FloorRevoke  Event = "floorRevoke"
+
+	// descp breakout events, Breakout tells a device the breakouts and where it is
+	Breakout          Event = "breakout"
+	BreakoutOpen      Event = "breakoutOpen"
+	BreakoutClose     Event = "breakoutClose"
+	BreakoutClosing   Event = "breakoutClosing"
+	BreakoutBroadcast Event = "breakoutBroadcast"
+	BreakoutAssign    Event = "breakoutAssign"
+	BreakoutJoin      Event = "breakoutJoin"
+
+	// descp moderation events, only host or co-host can send them
+	Kick         Event = "kick"
+	Mute         Event = "mute"
+	RoleChange   Event = "role"
+	TransferHost Event = "transferHost"
+	End          Event = "end"
+	Overtime     Event = "overtime" // descp scheduled meeting runs past its duration, carries seconds left
+	Redirect     Event = "redirect" // descp the room is owned by another node, join there instead
+	RoomState    Event = "room_state"
+	Negotiation  Event = "negotiation" // descp glare hint of perfect negotiation
+	IceServers   Event = "iceServers"  // descp fresh TURN credentials sent before the last ones expire
+	Lock         Event = "lock"
+	Unlock       Event = "unlock"
+
+	// descp lobby events, Waiting is sent to the held device, Lobby lists the held devices to host and co-host
+	Waiting Event = "waiting"
+	Lobby   Event = "lobby"
+	Admit   Event = "admit"
+	Deny    Event = "deny"
+)
+
+type MemberRole string
+
+const (
+	Host        MemberRole = "host"
+	CoHost      MemberRole = "coHost"
+	Participant MemberRole = "participant"
 )
 
 type EmitEvent int
@@ -26,4 +84,5 @@ const (
 	Join
 	Err
 	Close
+	Resume
 )
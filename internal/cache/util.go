@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"time"
 	"volo_meeting/consts"
@@ -39,6 +40,11 @@ func ZRevRange(ctx context.Context, key string, start, stop int64) ([]string, er
 	return data, wrap(err)
 }
 
+func ZRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	data, err := instance.ZRange(ctx, key, start, stop).Result()
+	return data, wrap(err)
+}
+
 func ZAdd(ctx context.Context, key string, members []redis.Z) error {
 
 	return wrap(instance.ZAdd(ctx, key, members...).Err())
@@ -58,10 +64,50 @@ func Get(ctx context.Context, key string) (string, error) {
 	return data, wrap(err)
 }
 
+// Incr : the expiration is only set by the first increment
+func Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	data, err := instance.Incr(ctx, key).Result()
+	if err == nil && data == 1 {
+		err = instance.Expire(ctx, key, expiration).Err()
+	}
+	return data, wrap(err)
+}
+
+// GetInt : a missing key is regarded as 0
+func GetInt(ctx context.Context, key string) (int64, error) {
+	data, err := instance.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return data, wrap(err)
+}
+
+// GetString : a missing key is regarded as ""
+func GetString(ctx context.Context, key string) (string, error) {
+	data, err := instance.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return data, wrap(err)
+}
+
 func Del(ctx context.Context, keys ...string) error {
 	return wrap(instance.Del(ctx, keys...).Err())
 }
 
+func Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	return wrap(instance.Set(ctx, key, value, expiration).Err())
+}
+
+func Exists(ctx context.Context, key string) (bool, error) {
+	data, err := instance.Exists(ctx, key).Result()
+	return data > 0, wrap(err)
+}
+
+func Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return wrap(instance.Expire(ctx, key, expiration).Err())
+}
+
 func HSet(ctx context.Context, key string, value map[string]any) error {
 	return wrap(instance.HSet(ctx, key, value).Err())
 }
@@ -70,3 +116,41 @@ func HSet(ctx context.Context, key string, value map[string]any) error {
 func HGet(ctx context.Context, key string, data any) error {
 	return wrap(instance.HGetAll(ctx, key).Scan(data))
 }
+
+func HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	data, err := instance.HGetAll(ctx, key).Result()
+	return data, wrap(err)
+}
+
+// HGetString : a missing key or field is regarded as ""
+func HGetString(ctx context.Context, key, field string) (string, error) {
+	data, err := instance.HGet(ctx, key, field).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return data, wrap(err)
+}
+
+func HDel(ctx context.Context, key string, fields ...string) error {
+	return wrap(instance.HDel(ctx, key, fields...).Err())
+}
+
+func HLen(ctx context.Context, key string) (int64, error) {
+	data, err := instance.HLen(ctx, key).Result()
+	return data, wrap(err)
+}
+
+// Eval : the script runs atomically, it is loaded by its sha1 first
+func Eval(ctx context.Context, script *redis.Script, keys []string, args ...any) (any, error) {
+	data, err := script.Run(ctx, instance, keys, args...).Result()
+	return data, wrap(err)
+}
+
+func Publish(ctx context.Context, channel string, message any) error {
+	return wrap(instance.Publish(ctx, channel, message).Err())
+}
+
+// Subscribe : more channels can be added by PubSub.Subscribe later
+func Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return instance.Subscribe(ctx, channels...)
+}
@@ -304,3 +304,179 @@ func TestToMap(t *testing.T) {
 		})
 	}
 }
+
+// newIncrKey descp: a key of the test alone increased times, deleted after the test
+func newIncrKey(t *testing.T, times int) string {
+	key := "incr:" + id.Must()
+	t.Cleanup(func() {
+		if err := Del(context.TODO(), key); err != nil {
+			t.Errorf("Del() error = %v", err)
+		}
+	})
+	for i := 0; i < times; i++ {
+		if _, err := Incr(context.TODO(), key, time.Second*10); err != nil {
+			t.Fatalf("Incr() error = %v", err)
+		}
+	}
+	return key
+}
+
+func TestIncr(t *testing.T) {
+	key := newIncrKey(t, 0)
+	type args struct {
+		ctx    context.Context
+		key    string
+		expire time.Duration
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    int64
+		wantErr bool
+	}{
+		{name: "first", args: args{ctx: context.TODO(), key: key, expire: time.Second * 10}, want: 1},
+		{name: "second", args: args{ctx: context.TODO(), key: key, expire: time.Second * 10}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Incr(tt.args.ctx, tt.args.key, tt.args.expire)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Incr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Incr() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetInt(t *testing.T) {
+	key := newIncrKey(t, 2)
+	type args struct {
+		ctx context.Context
+		key string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    int64
+		wantErr bool
+	}{
+		{name: "exist", args: args{ctx: context.TODO(), key: key}, want: 2},
+		{name: "missing", args: args{ctx: context.TODO(), key: "missing"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetInt(tt.args.ctx, tt.args.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetInt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetInt() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetString(t *testing.T) {
+	key := newIncrKey(t, 2)
+	type args struct {
+		ctx context.Context
+		key string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{name: "exist", args: args{ctx: context.TODO(), key: key}, want: "2"},
+		{name: "missing", args: args{ctx: context.TODO(), key: "missing"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetString(tt.args.ctx, tt.args.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetString() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHGetAll(t *testing.T) {
+	type args struct {
+		ctx context.Context
+		key string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantLen int
+		wantErr bool
+	}{
+		{name: "exist", args: args{ctx: context.TODO(), key: devices[0].Id}, wantLen: len(devices[0].ToMap())},
+		{name: "missing", args: args{ctx: context.TODO(), key: "missing"}, wantLen: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HGetAll(tt.args.ctx, tt.args.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HGetAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantLen {
+				t.Errorf("HGetAll() got = %v, want len %v", got, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestHDel(t *testing.T) {
+	type args struct {
+		ctx    context.Context
+		key    string
+		fields []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantLen int64
+		wantErr bool
+	}{
+		{name: "t1", args: args{ctx: context.TODO(), key: devices[1].Id, fields: []string{"nickname", "platform"}}, wantLen: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := HDel(tt.args.ctx, tt.args.key, tt.args.fields...); (err != nil) != tt.wantErr {
+				t.Errorf("HDel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got, _ := HLen(tt.args.ctx, tt.args.key); got != tt.wantLen {
+				t.Errorf("HLen() got = %v, want %v", got, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.TODO()
+	pubsub := Subscribe(ctx, "channel")
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Publish(ctx, "channel", "hello"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	select {
+	case msg := <-pubsub.Channel():
+		if msg.Payload != "hello" {
+			t.Errorf("Subscribe() got = %v, want hello", msg.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Error("Subscribe() got nothing")
+	}
+}
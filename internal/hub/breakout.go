@@ -0,0 +1,410 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+	"volo_meeting/consts"
+	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/tsmap"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+// Breakout descp: a named sub-room of Room, broadcast and deliver inside it stay scoped to its Members
+type Breakout struct {
+	Name    string
+	Members Members
+}
+
+type BreakoutOpenOption struct {
+	Rooms       []string            `json:"rooms"`
+	Assignments map[DeviceId]string `json:"assignments"`
+	SelfSelect  bool                `json:"self_select"` // descp members can move themselves by consts.BreakoutJoin
+}
+
+type BreakoutCloseOption struct {
+	Countdown int `json:"countdown"` // descp seconds before everyone is pulled back
+}
+
+// BreakoutTarget descp: Id is ignored by consts.BreakoutJoin, empty Name means the main room
+type BreakoutTarget struct {
+	Id   DeviceId `json:"id"`
+	Name string   `json:"name"`
+}
+
+// BreakoutState descp: empty Rooms means no breakouts, empty Current means in the main room
+type BreakoutState struct {
+	Rooms      []string `json:"rooms"`
+	Current    string   `json:"current"`
+	SelfSelect bool     `json:"self_select"`
+}
+
+type BreakoutNotice struct {
+	Id      DeviceId `json:"id"`
+	Content string   `json:"content"`
+}
+
+// breakoutLayout descp: the breakouts shared between nodes, zero Deadline means not closing.
+// the node closing the breakouts pulls everyone back, the others only keep the deadline
+type breakoutLayout struct {
+	Rooms       []string            `json:"rooms"`
+	Assignments map[DeviceId]string `json:"assignments"`
+	SelfSelect  bool                `json:"self_select"`
+	Deadline    int64               `json:"deadline"` // descp unix milliseconds
+}
+
+// peers descp: the members sharing the same breakout or main room with m
+func (m *Member) peers() Members {
+	m.Room.breakoutLock.Lock()
+	defer m.Room.breakoutLock.Unlock()
+
+	return m.scope()
+}
+
+// breakoutName descp: empty means in the main room
+func (m *Member) breakoutName() string {
+	m.Room.breakoutLock.Lock()
+	defer m.Room.breakoutLock.Unlock()
+
+	if m.breakout == nil {
+		return ""
+	}
+	return m.breakout.Name
+}
+
+// scope descp: caller must hold breakoutLock
+func (m *Member) scope() Members {
+	if m.breakout != nil {
+		return m.breakout.Members
+	}
+	return m.Room.Main
+}
+
+// enter descp: put the joining member into its assigned breakout or the main room
+func (r *Room) enter(member *Member) {
+	r.breakoutLock.Lock()
+	defer r.breakoutLock.Unlock()
+
+	member.breakout = nil
+	if name, ok := r.assignments[member.Device.Id]; ok {
+		member.breakout = r.breakouts[name]
+	}
+	member.scope().Set(member.Device.Id, member)
+}
+
+// leave descp: remove the member from its breakout or the main room, return the members left behind
+func (r *Room) leave(member *Member) Members {
+	r.breakoutLock.Lock()
+	defer r.breakoutLock.Unlock()
+
+	scope := member.scope()
+	if current, ok := scope.Get(member.Device.Id); ok && current == member {
+		scope.Delete(member.Device.Id)
+	}
+	return scope
+}
+
+func (r *Room) layout() *breakoutLayout {
+	r.breakoutLock.Lock()
+	defer r.breakoutLock.Unlock()
+
+	layout := &breakoutLayout{
+		Rooms:       make([]string, 0, len(r.breakouts)),
+		Assignments: make(map[DeviceId]string, len(r.assignments)),
+		SelfSelect:  r.selfSelect,
+	}
+	for name := range r.breakouts {
+		layout.Rooms = append(layout.Rooms, name)
+	}
+	sort.Strings(layout.Rooms)
+	for deviceId, name := range r.assignments {
+		layout.Assignments[deviceId] = name
+	}
+	if !r.breakoutDeadline.IsZero() {
+		layout.Deadline = r.breakoutDeadline.UnixMilli()
+	}
+	return layout
+}
+
+// applyBreakouts descp: apply the breakouts changed by another node, which has told every member,
+// so the members are only put into their places here
+func (r *Room) applyBreakouts(layout *breakoutLayout) {
+	members := r.snapshot()
+	moved := make([]DeviceId, 0)
+
+	r.breakoutLock.Lock()
+	r.breakouts = make(map[string]*Breakout, len(layout.Rooms))
+	for _, name := range layout.Rooms {
+		r.breakouts[name] = &Breakout{Name: name, Members: tsmap.New[DeviceId, *Member]()}
+	}
+	r.assignments = make(map[DeviceId]string, len(layout.Assignments))
+	for deviceId, name := range layout.Assignments {
+		if _, ok := r.breakouts[name]; ok {
+			r.assignments[deviceId] = name
+		}
+	}
+	r.selfSelect = layout.SelfSelect
+	r.breakoutDeadline = time.Time{}
+	if layout.Deadline > 0 {
+		r.breakoutDeadline = time.UnixMilli(layout.Deadline)
+	}
+	if len(r.breakouts) == 0 && r.breakoutTimer != nil {
+		r.breakoutTimer.Stop()
+		r.breakoutTimer = nil
+	}
+
+	for _, member := range members {
+		from := ""
+		if member.breakout != nil {
+			from = member.breakout.Name
+		}
+		r.Main.Delete(member.Device.Id)
+		member.breakout = nil
+		if name, ok := r.assignments[member.Device.Id]; ok {
+			member.breakout = r.breakouts[name]
+		}
+		member.scope().Set(member.Device.Id, member)
+		if from != r.assignments[member.Device.Id] {
+			moved = append(moved, member.Device.Id)
+		}
+	}
+	r.breakoutLock.Unlock()
+
+	for _, deviceId := range moved {
+		r.negotiations.forget(deviceId)
+	}
+}
+
+func (r *Room) breakoutState(member *Member) *BreakoutState {
+	r.breakoutLock.Lock()
+	defer r.breakoutLock.Unlock()
+
+	state := &BreakoutState{Rooms: make([]string, 0, len(r.breakouts)), SelfSelect: r.selfSelect}
+	for name := range r.breakouts {
+		state.Rooms = append(state.Rooms, name)
+	}
+	sort.Strings(state.Rooms)
+	if member.breakout != nil {
+		state.Current = member.breakout.Name
+	}
+	return state
+}
+
+// move descp: move the member into the breakout named name, empty name means the main room.
+// the old peers get consts.Leave, the member gets its new state and peers, the new peers get consts.Member
+func (r *Room) move(member *Member, name string) error {
+	r.breakoutLock.Lock()
+	var target *Breakout
+	if name != "" {
+		var ok bool
+		if target, ok = r.breakouts[name]; !ok {
+			r.breakoutLock.Unlock()
+			return error2.NotFound("breakout not found: " + name)
+		}
+	}
+
+	from := member.scope()
+	if member.breakout == target {
+		r.breakoutLock.Unlock()
+		return nil
+	}
+
+	from.Delete(member.Device.Id)
+	member.breakout = target
+	to := member.scope()
+	to.Set(member.Device.Id, member)
+	if target != nil {
+		r.assignments[member.Device.Id] = name
+	} else {
+		delete(r.assignments, member.Device.Id)
+	}
+	r.breakoutLock.Unlock()
+
+	zap.L().Debug("move member", zap.String("deviceId", member.Device.Id), zap.String("breakout", name))
+	r.negotiations.forget(member.Device.Id)
+
+	patch(r, from, consts.Leave, member.Device.Id)
+	sendTo(member, &Message[*BreakoutState]{member.NextId(), consts.Breakout, r.breakoutState(member)})
+	sendTo(member, &Message[[]*Peer]{member.NextId(), consts.Member, toPeers(member.Device.Id, getDevices(to, member.Device.Id))})
+	patchEach(r, to, consts.Member, func(receiver *Member) []*Peer {
+		return toPeers(receiver.Device.Id, []*Device{member.device()})
+	}, member.Device.Id)
+
+	return nil
+}
+
+func (r *Room) openBreakouts(option *BreakoutOpenOption) error {
+	r.breakoutLock.Lock()
+	if len(r.breakouts) > 0 {
+		r.breakoutLock.Unlock()
+		return error2.New(consts.ParamError, errors.New("breakouts have been opened"))
+	}
+	if len(option.Rooms) == 0 {
+		r.breakoutLock.Unlock()
+		return error2.New(consts.ParamError, errors.New("empty breakouts"))
+	}
+
+	for _, name := range option.Rooms {
+		if _, ok := r.breakouts[name]; name == "" || utf8.RuneCountInString(name) > consts.MaxBreakoutName || ok {
+			r.breakouts = make(map[string]*Breakout)
+			r.breakoutLock.Unlock()
+			return error2.New(consts.ParamError, fmt.Errorf("invalid breakout name: %q", name))
+		}
+		r.breakouts[name] = &Breakout{Name: name, Members: tsmap.New[DeviceId, *Member]()}
+	}
+
+	r.assignments = make(map[DeviceId]string, len(option.Assignments))
+	for deviceId, name := range option.Assignments {
+		if _, ok := r.breakouts[name]; ok {
+			r.assignments[deviceId] = name
+		}
+	}
+	assignments := make(map[DeviceId]string, len(r.assignments))
+	for deviceId, name := range r.assignments {
+		assignments[deviceId] = name
+	}
+	r.selfSelect = option.SelfSelect
+	if r.breakoutTimer != nil {
+		r.breakoutTimer.Stop()
+		r.breakoutTimer = nil
+	}
+	r.breakoutLock.Unlock()
+
+	for deviceId, name := range assignments {
+		if member, ok := r.Members.Get(deviceId); ok {
+			if err := r.move(member, name); err != nil {
+				zap.L().Error("move member error", zap.Error(err))
+			}
+		}
+	}
+
+	// descp the moved members have got their state in move
+	for _, member := range r.snapshot() {
+		if _, ok := assignments[member.Device.Id]; !ok {
+			sendTo(member, &Message[*BreakoutState]{member.NextId(), consts.Breakout, r.breakoutState(member)})
+		}
+	}
+
+	return nil
+}
+
+// closeBreakouts descp: tell everyone the countdown, then pull everyone back into the main room
+func (r *Room) closeBreakouts(countdown time.Duration) error {
+	r.breakoutLock.Lock()
+	if len(r.breakouts) == 0 {
+		r.breakoutLock.Unlock()
+		return error2.New(consts.ParamError, errors.New("no breakouts opened"))
+	}
+	if r.breakoutTimer != nil {
+		r.breakoutTimer.Stop()
+	}
+	r.breakoutTimer = time.AfterFunc(countdown, r.recallBreakouts)
+	r.breakoutDeadline = time.Now().Add(countdown)
+	r.breakoutLock.Unlock()
+
+	patch(r, r.Members, consts.BreakoutClosing, int(countdown/time.Second))
+	return nil
+}
+
+func (r *Room) recallBreakouts() {
+	for _, member := range r.snapshot() {
+		if err := r.move(member, ""); err != nil {
+			zap.L().Error("move member error", zap.Error(err))
+		}
+	}
+
+	r.breakoutLock.Lock()
+	r.breakouts = make(map[string]*Breakout)
+	r.assignments = make(map[DeviceId]string)
+	r.selfSelect = false
+	r.breakoutTimer = nil
+	r.breakoutDeadline = time.Time{}
+	r.breakoutLock.Unlock()
+
+	for _, member := range r.snapshot() {
+		sendTo(member, &Message[*BreakoutState]{member.NextId(), consts.Breakout, r.breakoutState(member)})
+	}
+	Global.cluster.shareBreakouts(r)
+}
+
+// snapshot descp: copy the members out, so that the caller can lock others while iterating
+func (r *Room) snapshot() []*Member {
+	members := make([]*Member, 0, r.Members.Len())
+	r.Members.Range(func(key DeviceId, value *Member) {
+		members = append(members, value)
+	})
+	return members
+}
+
+// handleBreakout descp: consts.BreakoutJoin is allowed for everyone when self select is on,
+// the others are only for host and co-host
+func (m *Member) handleBreakout(message *Message[jsoniter.RawMessage]) {
+	allowed := m.device().canModerate()
+	if message.Event == consts.BreakoutJoin && !allowed {
+		m.Room.breakoutLock.Lock()
+		allowed = m.Room.selfSelect
+		m.Room.breakoutLock.Unlock()
+	}
+	if !allowed {
+		m.Conn.Emit(consts.Err, error2.NoPermission, message.Id)
+		return
+	}
+
+	zap.L().Debug("breakout", zap.String("deviceId", m.Device.Id), zap.Any("event", message.Event))
+
+	var err error
+	switch message.Event {
+	case consts.BreakoutOpen:
+		option := &BreakoutOpenOption{}
+		if err = unmarshal(message.Data, option); err == nil {
+			err = m.Room.openBreakouts(option)
+		}
+	case consts.BreakoutClose:
+		option := &BreakoutCloseOption{}
+		if err = unmarshal(message.Data, option); err == nil {
+			err = m.Room.closeBreakouts(time.Duration(option.Countdown) * time.Second)
+		}
+	case consts.BreakoutBroadcast:
+		content := &ChatContent{}
+		if err = unmarshal(message.Data, content); err == nil {
+			if err = content.validate(); err == nil {
+				broadcast(m.Room.Members, consts.BreakoutBroadcast, &BreakoutNotice{m.Device.Id, content.Content})
+			}
+		}
+	case consts.BreakoutAssign, consts.BreakoutJoin:
+		target := &BreakoutTarget{}
+		if err = unmarshal(message.Data, target); err != nil {
+			break
+		}
+		member, ok := m, true
+		if message.Event == consts.BreakoutAssign {
+			member, ok = m.Room.Members.Get(target.Id)
+		}
+		if !ok {
+			err = error2.NotFound("member not found: " + target.Id)
+			break
+		}
+		err = m.Room.move(member, target.Name)
+	}
+
+	if err != nil {
+		m.Conn.Emit(consts.Err, err, message.Id)
+		return
+	}
+	if message.Event != consts.BreakoutBroadcast {
+		Global.cluster.shareBreakouts(m.Room)
+	}
+}
+
+func unmarshal(data jsoniter.RawMessage, v any) error {
+	err := jsoniter.Unmarshal(data, v)
+	if err != nil {
+		zap.L().Error("unmarshal error", zap.Error(err))
+		return error2.New(consts.MarshalError, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package hub
+
+import (
+	"strings"
+	"volo_meeting/lib/ice"
+	"volo_meeting/lib/sdp"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// candidatePolicies descp: the policies of config ice, the blocklist applies to the addresses before 1:1 nat
+func candidatePolicies() ice.Chain {
+	chain := make(ice.Chain, 0)
+
+	if cidrs := viper.GetStringSlice("ice.blocklist"); len(cidrs) > 0 {
+		blocklist, err := ice.NewBlocklist(cidrs)
+		if err != nil {
+			zap.L().Error("ice blocklist error", zap.Error(err))
+		} else {
+			chain = append(chain, blocklist)
+		}
+	}
+
+	if mapping := viper.GetStringMapString("ice.nat_1to1"); len(mapping) > 0 {
+		nat, err := ice.NewNAT1To1(mapping)
+		if err != nil {
+			zap.L().Error("ice 1:1 nat error", zap.Error(err))
+		} else {
+			chain = append(chain, nat)
+		}
+	}
+
+	if viper.GetBool("ice.strip_host") {
+		chain = append(chain, ice.StripHost{})
+	}
+	return chain
+}
+
+// candidates descp: the policies of the candidates sent by m
+func (m *Member) candidates() ice.Chain {
+	if !m.Device.RelayOnly {
+		return Global.candidates
+	}
+	chain := make(ice.Chain, 0, len(Global.candidates)+1)
+	return append(append(chain, Global.candidates...), ice.RelayOnly{})
+}
+
+// filterCandidates descp: rewrite or drop the candidates sent by m, the dropped ones are counted by ice.GetStats
+func (m *Member) filterCandidates(data []Data) []Data {
+	chain := m.candidates()
+	if len(chain) == 0 {
+		return data
+	}
+
+	filtered := data[:0]
+	for _, d := range data {
+		fields := make(map[string]jsoniter.RawMessage)
+		var raw string
+		if d.Content == nil || jsoniter.Unmarshal(*d.Content, &fields) != nil || jsoniter.Unmarshal(fields["candidate"], &raw) != nil {
+			filtered = append(filtered, d)
+			continue
+		}
+
+		candidate, keep := chain.Filter(raw)
+		if !keep {
+			zap.L().Debug("drop candidate", zap.String("deviceId", m.Device.Id), zap.String("to", d.Id), zap.String("candidate", raw))
+			continue
+		}
+		if candidate != raw {
+			fields["candidate"], _ = jsoniter.Marshal(candidate)
+			if content, err := jsoniter.Marshal(fields); err == nil {
+				d.Content = (*jsoniter.RawMessage)(&content)
+			} else {
+				zap.L().Error("marshal candidate error", zap.Error(err))
+			}
+		}
+		filtered = append(filtered, d)
+	}
+	return filtered
+}
+
+// filterDescription descp: filter the a=candidate lines of a description sent by m like the trickled candidates.
+// the address of a c= line is filtered as a host candidate, and turns unspecified if dropped
+func (m *Member) filterDescription(chain ice.Chain, session *sdp.Session) {
+	session.Rewrite("a=candidate:", func(line string) (string, bool) {
+		candidate, keep := chain.Filter(line)
+		if !keep {
+			zap.L().Debug("drop candidate", zap.String("deviceId", m.Device.Id), zap.String("candidate", line))
+		}
+		return candidate, keep
+	})
+	session.Rewrite("c=", func(line string) (string, bool) {
+		fields := strings.Fields(line[2:])
+		if len(fields) != 3 {
+			return line, true
+		}
+		address, ttl, _ := strings.Cut(fields[2], "/")
+		if filtered, keep := chain.FilterAddress(address); keep {
+			address = filtered
+		} else if fields[1] == "IP6" {
+			address = "::"
+		} else {
+			address = "0.0.0.0"
+		}
+		if ttl != "" {
+			address += "/" + ttl
+		}
+		return "c=" + strings.Join(append(fields[:2], address), " "), true
+	})
+}
@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"fmt"
+	"testing"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+	"volo_meeting/lib/ice"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestMember_Describe_Candidates(t *testing.T) {
+	blocklist, _ := ice.NewBlocklist([]string{"192.168.0.0/16"})
+	nat, _ := ice.NewNAT1To1(map[string]string{"10.0.0.5": "203.0.113.9"})
+	defer func(candidates ice.Chain) { Global.candidates = candidates }(Global.candidates)
+
+	offer := "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+		"m=audio 54321 UDP/TLS/RTP/SAVPF 111\r\nc=IN IP4 %v\r\na=mid:0\r\na=rtpmap:111 opus/48000/2\r\n" +
+		"a=candidate:1 1 udp 2122260223 10.0.0.5 54321 typ host generation 0\r\n" +
+		"a=candidate:2 1 udp 2122260223 192.168.1.7 54322 typ host generation 0\r\n" +
+		"a=candidate:3 1 udp 1686052607 203.0.113.5 54321 typ srflx raddr 10.0.0.5 rport 54321 generation 0\r\n" +
+		"a=end-of-candidates\r\n"
+	tests := []struct {
+		name       string
+		candidates ice.Chain
+		address    string
+		want       string
+	}{
+		{name: "no policy", candidates: ice.Chain{}, address: "10.0.0.5", want: "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+			"m=audio 54321 UDP/TLS/RTP/SAVPF 111\r\nc=IN IP4 10.0.0.5\r\na=mid:0\r\na=rtpmap:111 opus/48000/2\r\n" +
+			"a=candidate:1 1 udp 2122260223 10.0.0.5 54321 typ host generation 0\r\n" +
+			"a=candidate:2 1 udp 2122260223 192.168.1.7 54322 typ host generation 0\r\n" +
+			"a=candidate:3 1 udp 1686052607 203.0.113.5 54321 typ srflx raddr 10.0.0.5 rport 54321 generation 0\r\n" +
+			"a=end-of-candidates\r\n"},
+		{name: "blocklist and 1:1 nat", candidates: ice.Chain{blocklist, nat}, address: "10.0.0.5", want: "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+			"m=audio 54321 UDP/TLS/RTP/SAVPF 111\r\nc=IN IP4 203.0.113.9\r\na=mid:0\r\na=rtpmap:111 opus/48000/2\r\n" +
+			"a=candidate:1 1 udp 2122260223 203.0.113.9 54321 typ host generation 0\r\n" +
+			"a=candidate:3 1 udp 1686052607 203.0.113.5 54321 typ srflx raddr 203.0.113.9 rport 54321 generation 0\r\n" +
+			"a=end-of-candidates\r\n"},
+		{name: "blocked default address", candidates: ice.Chain{blocklist}, address: "192.168.1.7", want: "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+			"m=audio 54321 UDP/TLS/RTP/SAVPF 111\r\nc=IN IP4 0.0.0.0\r\na=mid:0\r\na=rtpmap:111 opus/48000/2\r\n" +
+			"a=candidate:1 1 udp 2122260223 10.0.0.5 54321 typ host generation 0\r\n" +
+			"a=candidate:3 1 udp 1686052607 203.0.113.5 54321 typ srflx raddr 10.0.0.5 rport 54321 generation 0\r\n" +
+			"a=end-of-candidates\r\n"},
+		{name: "strip host", candidates: ice.Chain{ice.StripHost{}}, address: "10.0.0.5", want: "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+			"m=audio 54321 UDP/TLS/RTP/SAVPF 111\r\nc=IN IP4 0.0.0.0\r\na=mid:0\r\na=rtpmap:111 opus/48000/2\r\n" +
+			"a=candidate:3 1 udp 1686052607 203.0.113.5 54321 typ srflx raddr 10.0.0.5 rport 54321 generation 0\r\n" +
+			"a=end-of-candidates\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Global.candidates = tt.candidates
+			r := newTestRoom(&model.Meeting{})
+			m, _ := addMember(t, r, "a", consts.Participant)
+
+			content, _ := jsoniter.Marshal(&sessionDescription{Type: offerType, Sdp: fmt.Sprintf(offer, tt.address)})
+			data := m.describe(1, []Data{{Id: "b", Content: (*jsoniter.RawMessage)(&content)}})
+			if len(data) != 1 {
+				t.Fatalf("describe() = %v, want 1 description", data)
+			}
+			desc := &sessionDescription{}
+			if err := jsoniter.Unmarshal(*data[0].Content, desc); err != nil {
+				t.Fatal(err)
+			}
+			if desc.Sdp != tt.want {
+				t.Errorf("describe() sdp = %q, want %q", desc.Sdp, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package hub
+
+import (
+	"volo_meeting/consts"
+	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/ws"
+
+	"go.uber.org/zap"
+)
+
+// checkJoin descp: a new device can't join a locked room unless it is the creator,
+// and nobody can join a room which has reached Meeting.MaxParticipants
+func (r *Room) checkJoin(device *Device) (consts.ErrorId, error) {
+	if r.isLocked() && !device.Creator {
+		return consts.LockedRoom, error2.LockedRoom
+	}
+
+	if r.Meeting.MaxParticipants > 0 && r.Members.Len() >= r.Meeting.MaxParticipants {
+		return consts.FullRoom, error2.FullRoom
+	}
+
+	return 0, nil
+}
+
+func (r *Room) setLocked(locked bool) {
+	r.locked.Store(locked)
+}
+
+func (r *Room) isLocked() bool {
+	return r.locked.Load()
+}
+
+// reject descp: the conn has no member entry yet, so send the error and close it directly once it is written
+func reject(device *Device, conn *ws.Conn, errId consts.ErrorId, err error) {
+	zap.L().Debug("refuse join", zap.String("deviceId", device.Id), zap.Error(err))
+	conn.Send(&Message[error]{errId, consts.Error, err})
+	conn.CloseAfterFlush()
+}
@@ -0,0 +1,112 @@
+package hub
+
+import (
+	"testing"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestRoom_CheckJoin(t *testing.T) {
+	tests := []struct {
+		name      string
+		meeting   *model.Meeting
+		locked    bool
+		deviceId  DeviceId
+		creator   bool
+		wantErrId consts.ErrorId
+	}{
+		{name: "room with space", meeting: &model.Meeting{MaxParticipants: 2}, deviceId: "b", wantErrId: 0},
+		{name: "no limit", meeting: &model.Meeting{}, deviceId: "b", wantErrId: 0},
+		{name: "full room", meeting: &model.Meeting{MaxParticipants: 1}, deviceId: "b", wantErrId: consts.FullRoom},
+		{name: "locked room", meeting: &model.Meeting{}, locked: true, deviceId: "b", wantErrId: consts.LockedRoom},
+		{name: "creator joins locked room", meeting: &model.Meeting{HostId: "b"}, locked: true, deviceId: "b", creator: true, wantErrId: 0},
+		{name: "host id spoofed on locked room", meeting: &model.Meeting{HostId: "b"}, locked: true, deviceId: "b", wantErrId: consts.LockedRoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(tt.meeting)
+			addMember(t, r, "a", consts.Participant)
+			r.setLocked(tt.locked)
+
+			errId, err := r.checkJoin(&Device{Id: tt.deviceId, Creator: tt.creator})
+			if errId != tt.wantErrId || (err != nil) != (tt.wantErrId != 0) {
+				t.Errorf("checkJoin() = %v %v, want %v", errId, err, tt.wantErrId)
+			}
+		})
+	}
+}
+
+func TestMember_Lock(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       consts.MemberRole
+		event      consts.Event
+		wantLocked bool
+	}{
+		{name: "host locks", role: consts.Host, event: consts.Lock, wantLocked: true},
+		{name: "co-host locks", role: consts.CoHost, event: consts.Lock, wantLocked: true},
+		{name: "participant can't lock", role: consts.Participant, event: consts.Lock, wantLocked: false},
+		{name: "host unlocks", role: consts.Host, event: consts.Unlock, wantLocked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(&model.Meeting{})
+			moderator, moderatorClient := addMember(t, r, "a", tt.role)
+			_, peerClient := addMember(t, r, "b", consts.Participant)
+			moderator.setupEmitter()
+			r.setLocked(tt.event == consts.Unlock)
+
+			moderator.moderate(&Message[jsoniter.RawMessage]{3, tt.event, nil})
+
+			if r.isLocked() != tt.wantLocked {
+				t.Errorf("moderate() locked = %v, want %v", r.isLocked(), tt.wantLocked)
+			}
+			if tt.role == consts.Participant {
+				if message := readMessage[*jsoniter.RawMessage](t, moderatorClient, consts.Error); message.Id != 3 {
+					t.Errorf("moderate() error id = %v, want 3", message.Id)
+				}
+				return
+			}
+			if message := readMessage[DeviceId](t, peerClient, tt.event); message.Data != "a" {
+				t.Errorf("moderate() %v by = %v, want a", tt.event, message.Data)
+			}
+		})
+	}
+}
+
+func TestHub_JoinRoom_Reject(t *testing.T) {
+	tests := []struct {
+		name      string
+		meeting   *model.Meeting
+		locked    bool
+		wantErrId consts.ErrorId
+	}{
+		{name: "full room", meeting: &model.Meeting{MaxParticipants: 1}, wantErrId: consts.FullRoom},
+		{name: "locked room", meeting: &model.Meeting{}, locked: true, wantErrId: consts.LockedRoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHub()
+			r := newTestRoom(tt.meeting)
+			h.rooms.Set(r.Meeting.Id, r)
+			addMember(t, r, "a", consts.Participant)
+			r.setLocked(tt.locked)
+
+			conn, client := newConn(t)
+			h.JoinRoom(r.Meeting.Id, &Device{Id: "b"}, conn, "")
+
+			if message := readMessage[*jsoniter.RawMessage](t, client, consts.Error); message.Id != tt.wantErrId {
+				t.Errorf("JoinRoom() error id = %v, want %v", message.Id, tt.wantErrId)
+			}
+			if _, _, err := client.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("ReadMessage() error = %v, want normal closure after reject", err)
+			}
+			if _, ok := r.Members.Get("b"); ok {
+				t.Error("JoinRoom() want b rejected")
+			}
+		})
+	}
+}
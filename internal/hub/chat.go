@@ -0,0 +1,126 @@
+package hub
+
+import (
+	"errors"
+	"unicode/utf8"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+	error2 "volo_meeting/lib/error"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+type ChatContent struct {
+	Ids     []DeviceId `json:"ids,omitempty"` // descp recipients, only used by consts.Whisper
+	Content string     `json:"content"`
+}
+
+func (c *ChatContent) validate() error {
+	length := utf8.RuneCountInString(c.Content)
+	if length == 0 || length > consts.MaxChatLength {
+		return error2.New(consts.ParamError, errors.New("invalid chat content length"))
+	}
+	return nil
+}
+
+// chat descp: persist the chat message with the breakout of sender, then broadcast it to all devices in the same breakout
+// or main room including the sender, consts.Chat always carries a list of model.Chat
+func (m *Member) chat(message *Message[jsoniter.RawMessage]) {
+	content := &ChatContent{}
+	err := jsoniter.Unmarshal(message.Data, content)
+	if err != nil {
+		zap.L().Error("unmarshal error", zap.Error(err))
+		sendTo(m, &Message[error]{message.Id, consts.Error, error2.New(consts.MarshalError, err)})
+		return
+	}
+
+	if err = content.validate(); err != nil {
+		m.Conn.Emit(consts.Err, err, message.Id)
+		return
+	}
+
+	chat := &model.Chat{
+		MeetingId: m.Room.Meeting.Id,
+		Breakout:  m.breakoutName(),
+		SenderId:  m.Device.Id,
+		Nickname:  m.device().Nickname,
+		Content:   content.Content,
+	}
+	if err = chat.Create(model.Instance()); err != nil {
+		zap.L().Error("create chat error", zap.Error(err))
+		m.Conn.Emit(consts.Err, error2.New(consts.SqlError, err), message.Id)
+		return
+	}
+
+	broadcast(m.peers(), consts.Chat, []*model.Chat{chat})
+}
+
+// whisper descp: persist the private chat, then send it only to the recipients and the sender,
+// the unknown recipients are replied to the sender by consts.Unreachable
+func (m *Member) whisper(message *Message[jsoniter.RawMessage]) {
+	content := &ChatContent{}
+	err := jsoniter.Unmarshal(message.Data, content)
+	if err != nil {
+		zap.L().Error("unmarshal error", zap.Error(err))
+		sendTo(m, &Message[error]{message.Id, consts.Error, error2.New(consts.MarshalError, err)})
+		return
+	}
+
+	if err = content.validate(); err != nil {
+		m.Conn.Emit(consts.Err, err, message.Id)
+		return
+	}
+
+	recipients := make([]*Member, 0, len(content.Ids))
+	unknown := make([]DeviceId, 0)
+	for _, id := range content.Ids {
+		member, ok := m.Room.Members.Get(id)
+		if !ok || member == m {
+			unknown = append(unknown, id)
+			continue
+		}
+		recipients = append(recipients, member)
+	}
+	if len(unknown) > 0 {
+		sendTo(m, &Message[[]DeviceId]{message.Id, consts.Unreachable, unknown})
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	chat := &model.Chat{
+		MeetingId:  m.Room.Meeting.Id,
+		SenderId:   m.Device.Id,
+		Nickname:   m.device().Nickname,
+		Content:    content.Content,
+		Private:    true,
+		Recipients: make([]model.ChatRecipient, 0, len(recipients)),
+	}
+	for _, member := range recipients {
+		chat.Recipients = append(chat.Recipients, model.ChatRecipient{DeviceId: member.Device.Id})
+	}
+	if err = chat.Create(model.Instance()); err != nil {
+		zap.L().Error("create chat error", zap.Error(err))
+		m.Conn.Emit(consts.Err, error2.New(consts.SqlError, err), message.Id)
+		return
+	}
+
+	for _, member := range append(recipients, m) {
+		sendTo(member, &Message[[]*model.Chat]{member.NextId(), consts.Whisper, []*model.Chat{chat}})
+	}
+}
+
+// sendChatHistory descp: late joiner gets the last consts.ChatHistorySize messages visible to it in its breakout or main room
+func (m *Member) sendChatHistory() {
+	chats, err := model.FindChats(model.Instance(), m.Room.Meeting.Id, m.Device.Id, m.breakoutName(), 0, consts.ChatHistorySize)
+	if err != nil {
+		zap.L().Error("find chats error", zap.Error(err))
+		return
+	}
+	if len(chats) == 0 {
+		return
+	}
+
+	sendTo(m, &Message[[]*model.Chat]{m.NextId(), consts.Chat, chats})
+}
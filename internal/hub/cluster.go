@@ -0,0 +1,856 @@
+package hub
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"volo_meeting/consts"
+	"volo_meeting/internal/cache"
+	"volo_meeting/lib/db/redis"
+	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/id"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// cluster descp: shares the rooms between nodes through redis, nil means running as a single node.
+// every node keeps the members connected to it, the ones on other nodes are kept as remote members,
+// and sendTo relays their messages through the pub/sub channel of the room.
+// the lock, floor, lobby and breakouts are kept in redis as well, the node making a change tells every member
+// and the envelope only brings the change to the state of the other nodes. the negotiations stay on each node,
+// as they are only tracked between the members of the same node
+type cluster struct {
+	node   string
+	pubsub *redis.PubSub
+
+	lock  sync.Mutex
+	rooms map[MeetingId]*Room // descp rooms subscribed by this node
+
+	pendingLock sync.Mutex
+	pending     []*outgoing   // descp the changes to write and publish, in order
+	flushing    chan struct{} // descp wakes up the publisher
+}
+
+// outgoing descp: a change of room waiting for the publisher, w is written into redis before data is published,
+// so that a node reading redis after the envelope sees the change
+type outgoing struct {
+	meetingId MeetingId
+	w         *write
+	data      string // descp the marshaled envelope, empty means nothing to publish
+}
+
+// write descp: the fields of the hash key to save and remove, the key lives as long as any node of the room is alive
+type write struct {
+	key    string
+	save   map[string]any
+	remove []string
+}
+
+// presence descp: a member in redis, Device.JoinTime is not marshaled so it is kept aside.
+// Digest is the digest of the session token, so that any node can check the token
+type presence struct {
+	Node     string  `json:"node"`
+	Device   *Device `json:"device"`
+	JoinTime int64   `json:"join_time"`
+	Digest   string  `json:"digest"`
+}
+
+type envelopeKind string
+
+const (
+	kindJoin   envelopeKind = "join"   // descp Members joined the origin node
+	kindLeave  envelopeKind = "leave"  // descp Target left the origin node
+	kindDevice envelopeKind = "device" // descp Members' devices have been changed
+	kindSend   envelopeKind = "send"   // descp send Event and Data to Target
+	kindQuit   envelopeKind = "quit"   // descp remove Target from the room, such as kicked
+	kindEnd    envelopeKind = "end"    // descp the meeting has been ended
+
+	kindLock     envelopeKind = "lock"     // descp the room is locked by Event consts.Lock, otherwise unlocked
+	kindFloor    envelopeKind = "floor"    // descp Data is the floorState in redis
+	kindBreakout envelopeKind = "breakout" // descp Data is the breakoutLayout
+	kindWait     envelopeKind = "wait"     // descp Members are held in the lobby of the origin node
+	kindUnwait   envelopeKind = "unwait"   // descp Target has left the lobby of the origin node
+	kindAdmit    envelopeKind = "admit"    // descp admit Target held in the lobby of another node
+	kindDeny     envelopeKind = "deny"     // descp deny Target held in the lobby of another node
+)
+
+// envelope descp: published to the channel of room in batches, every node ignores the ones from itself
+type envelope struct {
+	Kind    envelopeKind        `json:"kind"`
+	Node    string              `json:"node"`
+	Target  DeviceId            `json:"target,omitempty"`
+	Members []*presence         `json:"members,omitempty"`
+	Event   consts.Event        `json:"event,omitempty"`
+	Data    jsoniter.RawMessage `json:"data,omitempty"`
+}
+
+func heartbeatInterval() time.Duration {
+	if interval := viper.GetInt("cluster.heartbeat"); interval > 0 {
+		return time.Duration(interval) * time.Second
+	}
+	return consts.DefaultClusterHeartbeat
+}
+
+// presenceTTL descp: a node missing consts.PresenceTTLFactor heartbeats is regarded as dead
+func presenceTTL() time.Duration {
+	return heartbeatInterval() * consts.PresenceTTLFactor
+}
+
+func roomChannel(meetingId MeetingId) string {
+	return "cluster:room:" + meetingId
+}
+
+func membersKey(meetingId MeetingId) string {
+	return "cluster:members:" + meetingId
+}
+
+func nodeKey(node string) string {
+	return "cluster:node:" + node
+}
+
+// stateKey descp: the hash of the lock, breakouts and the version of floor
+func stateKey(meetingId MeetingId) string {
+	return "cluster:state:" + meetingId
+}
+
+// lobbyKey descp: the hash of the presences held in the lobby
+func lobbyKey(meetingId MeetingId) string {
+	return "cluster:lobby:" + meetingId
+}
+
+// floorKey descp: the sorted set of the floor holders, scored by the time granted
+func floorKey(meetingId MeetingId) string {
+	return "cluster:floor:" + meetingId
+}
+
+// stateKeys descp: the keys of the shared state of room besides the members
+func stateKeys(meetingId MeetingId) []string {
+	return []string{stateKey(meetingId), lobbyKey(meetingId), floorKey(meetingId)}
+}
+
+// grantScript descp: KEYS are floorKey and stateKey, ARGV are the device id, floor limit, score and ttl in ms.
+// return the new version followed by the holders, or floorHeld, floorFull
+var grantScript = redis.NewScript(`
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return {-1}
+end
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+	return {-2}
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+local version = redis.call("HINCRBY", KEYS[2], "floor_version", 1)
+redis.call("PEXPIRE", KEYS[2], ARGV[4])
+local result = redis.call("ZRANGE", KEYS[1], 0, -1)
+table.insert(result, 1, version)
+return result
+`)
+
+// releaseScript descp: KEYS are floorKey and stateKey, ARGV are the device id and ttl in ms.
+// return the new version followed by the holders, or floorHeld when the device doesn't hold the floor
+var releaseScript = redis.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return {-1}
+end
+local version = redis.call("HINCRBY", KEYS[2], "floor_version", 1)
+redis.call("PEXPIRE", KEYS[2], ARGV[2])
+local result = redis.call("ZRANGE", KEYS[1], 0, -1)
+table.insert(result, 1, version)
+return result
+`)
+
+const (
+	floorHeld int64 = -1 // descp nothing changed, the device holds the floor already or not at all
+	floorFull int64 = -2
+)
+
+func newCluster() *cluster {
+	node := viper.GetString("cluster.node")
+	if node == "" {
+		node = id.Must()
+	}
+
+	return &cluster{
+		node:     node,
+		pubsub:   cache.Subscribe(context.Background()),
+		rooms:    make(map[MeetingId]*Room),
+		flushing: make(chan struct{}, 1),
+	}
+}
+
+// start descp: announce the node and keep it alive, then handle the envelopes of the subscribed rooms
+func (c *cluster) start() {
+	zap.L().Info("cluster mode", zap.String("node", c.node))
+
+	c.beat()
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			c.beat()
+		}
+	}()
+
+	go func() {
+		for range c.flushing {
+			c.flush()
+		}
+	}()
+
+	go func() {
+		for msg := range c.pubsub.Channel() {
+			c.handle(strings.TrimPrefix(msg.Channel, roomChannel("")), msg.Payload)
+		}
+	}()
+}
+
+// attach descp: subscribe the room and load the members on the other alive nodes
+func (c *cluster) attach(r *Room) {
+	if c == nil {
+		return
+	}
+	ctx := context.Background()
+
+	c.lock.Lock()
+	c.rooms[r.Meeting.Id] = r
+	c.lock.Unlock()
+
+	if err := c.pubsub.Subscribe(ctx, roomChannel(r.Meeting.Id)); err != nil {
+		zap.L().Error("subscribe room error", zap.Error(err))
+	}
+
+	all, err := cache.HGetAll(ctx, membersKey(r.Meeting.Id))
+	if err != nil {
+		zap.L().Error("load room members error", zap.Error(err))
+		return
+	}
+	alive := make(map[string]bool)
+	for _, v := range all {
+		p := &presence{}
+		if err = jsoniter.UnmarshalFromString(v, p); err != nil || p.Node == c.node {
+			continue
+		}
+		if _, ok := alive[p.Node]; !ok {
+			alive[p.Node] = c.isAlive(p.Node)
+		}
+		if alive[p.Node] {
+			r.addRemote(p)
+		}
+	}
+
+	c.load(r, alive)
+}
+
+// load descp: bring the lock, floor, breakouts and lobby of the room in redis to the attached room
+func (c *cluster) load(r *Room, alive map[string]bool) {
+	ctx := context.Background()
+
+	state, err := cache.HGetAll(ctx, stateKey(r.Meeting.Id))
+	if err != nil {
+		zap.L().Error("load room state error", zap.Error(err))
+		return
+	}
+	r.setLocked(state["locked"] == "1")
+	if data := state["breakouts"]; data != "" {
+		layout := &breakoutLayout{}
+		if err = jsoniter.UnmarshalFromString(data, layout); err == nil {
+			r.applyBreakouts(layout)
+		}
+	}
+
+	holders, err := cache.ZRange(ctx, floorKey(r.Meeting.Id), 0, -1)
+	if err != nil {
+		zap.L().Error("load floor error", zap.Error(err))
+		return
+	}
+	version, _ := strconv.ParseInt(state["floor_version"], 10, 64)
+	r.setFloor(&floorState{Version: version, Holders: holders})
+
+	all, err := cache.HGetAll(ctx, lobbyKey(r.Meeting.Id))
+	if err != nil {
+		zap.L().Error("load lobby error", zap.Error(err))
+		return
+	}
+	for _, v := range all {
+		p := &presence{}
+		if err = jsoniter.UnmarshalFromString(v, p); err != nil || p.Node == c.node {
+			continue
+		}
+		if _, ok := alive[p.Node]; !ok {
+			alive[p.Node] = c.isAlive(p.Node)
+		}
+		if alive[p.Node] {
+			r.addRemoteWaiter(p)
+		}
+	}
+}
+
+// detach descp: stop receiving the envelopes of the room
+func (c *cluster) detach(meetingId MeetingId) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	delete(c.rooms, meetingId)
+	c.lock.Unlock()
+
+	if err := c.pubsub.Unsubscribe(context.Background(), roomChannel(meetingId)); err != nil {
+		zap.L().Error("unsubscribe room error", zap.Error(err))
+	}
+}
+
+func (c *cluster) getRoom(meetingId MeetingId) (*Room, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	r, ok := c.rooms[meetingId]
+	return r, ok
+}
+
+// publish descp: queue the envelope for the publisher, so the callers holding the locks of room never wait for redis.
+// w is written into redis before the envelope is published, nil means nothing to write
+func (c *cluster) publish(meetingId MeetingId, e *envelope, w *write) {
+	e.Node = c.node
+	data, err := jsoniter.MarshalToString(e)
+	if err != nil {
+		zap.L().Error("marshal envelope error", zap.Error(err))
+		return
+	}
+
+	c.pendingLock.Lock()
+	c.pending = append(c.pending, &outgoing{meetingId, w, data})
+	c.pendingLock.Unlock()
+
+	select {
+	case c.flushing <- struct{}{}:
+	default:
+	}
+}
+
+// flush descp: write the queued changes in order, then publish the envelopes, one batch per room keeping their order
+func (c *cluster) flush() {
+	c.pendingLock.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.pendingLock.Unlock()
+
+	order := make([]MeetingId, 0)
+	batches := make(map[MeetingId][]string)
+	for _, o := range pending {
+		if o.w != nil {
+			c.write(o.w)
+		}
+		if _, ok := batches[o.meetingId]; !ok {
+			order = append(order, o.meetingId)
+		}
+		batches[o.meetingId] = append(batches[o.meetingId], o.data)
+	}
+
+	for _, meetingId := range order {
+		data := "[" + strings.Join(batches[meetingId], ",") + "]"
+		if err := cache.Publish(context.Background(), roomChannel(meetingId), data); err != nil {
+			zap.L().Error("publish envelope error", zap.Error(err))
+		}
+	}
+}
+
+func (c *cluster) write(w *write) {
+	ctx := context.Background()
+	if len(w.save) > 0 {
+		if err := cache.HSet(ctx, w.key, w.save); err != nil {
+			zap.L().Error("save room state error", zap.String("key", w.key), zap.Error(err))
+		}
+		if err := cache.Expire(ctx, w.key, presenceTTL()); err != nil {
+			zap.L().Error("expire room state error", zap.String("key", w.key), zap.Error(err))
+		}
+	}
+	if len(w.remove) > 0 {
+		if err := cache.HDel(ctx, w.key, w.remove...); err != nil {
+			zap.L().Error("delete room state error", zap.String("key", w.key), zap.Error(err))
+		}
+	}
+}
+
+func (c *cluster) presence(m *Member) *presence {
+	node := m.node
+	if node == "" {
+		node = c.node
+	}
+	return &presence{Node: node, Device: m.device(), JoinTime: m.Device.JoinTime, Digest: m.digest()}
+}
+
+// checkSession descp: check the token of a member of the room which is not attached to this node
+func (c *cluster) checkSession(meetingId MeetingId, deviceId DeviceId, token string) bool {
+	if c == nil {
+		return false
+	}
+
+	all, err := cache.HGetAll(context.Background(), membersKey(meetingId))
+	if err != nil {
+		zap.L().Error("load members error", zap.Error(err))
+		return false
+	}
+	p := &presence{}
+	if v, ok := all[deviceId]; !ok || jsoniter.UnmarshalFromString(v, p) != nil {
+		return false
+	}
+	return matchDigest(p.Digest, token) && c.isAlive(p.Node)
+}
+
+// presences descp: the fields of the members hash
+func presences(members []*presence) map[string]any {
+	values := make(map[string]any, len(members))
+	for _, p := range members {
+		data, err := jsoniter.MarshalToString(p)
+		if err != nil {
+			zap.L().Error("marshal presence error", zap.Error(err))
+			continue
+		}
+		values[p.Device.Id] = data
+	}
+	return values
+}
+
+// join descp: only queued, so it is called under joinLock to keep the order with the leave of the member
+func (c *cluster) join(m *Member) {
+	if c == nil {
+		return
+	}
+	p := c.presence(m)
+	members := []*presence{p}
+	c.publish(m.Room.Meeting.Id, &envelope{Kind: kindJoin, Members: members}, &write{key: membersKey(m.Room.Meeting.Id), save: presences(members)})
+}
+
+func (c *cluster) leave(m *Member) {
+	if c == nil {
+		return
+	}
+	c.publish(m.Room.Meeting.Id, &envelope{Kind: kindLeave, Target: m.Device.Id}, &write{key: membersKey(m.Room.Meeting.Id), remove: []string{m.Device.Id}})
+}
+
+// sync descp: share the changed devices, wherever their members are
+func (c *cluster) sync(r *Room, devices ...*Device) {
+	if c == nil || len(devices) == 0 {
+		return
+	}
+	members := make([]*presence, 0, len(devices))
+	for _, device := range devices {
+		if m, ok := r.Members.Get(device.Id); ok {
+			members = append(members, c.presence(m))
+		}
+	}
+	c.publish(r.Meeting.Id, &envelope{Kind: kindDevice, Members: members}, &write{key: membersKey(r.Meeting.Id), save: presences(members)})
+}
+
+// relay descp: send the message to the remote member through its node, which assigns the message id
+func (c *cluster) relay(m *Member, event consts.Event, data any) {
+	raw, err := jsoniter.Marshal(data)
+	if err != nil {
+		zap.L().Error("marshal relay data error", zap.Error(err))
+		return
+	}
+	c.publish(m.Room.Meeting.Id, &envelope{Kind: kindSend, Target: m.Device.Id, Event: event, Data: raw}, nil)
+}
+
+func (c *cluster) quit(m *Member) {
+	c.publish(m.Room.Meeting.Id, &envelope{Kind: kindQuit, Target: m.Device.Id}, nil)
+}
+
+// end descp: the state of the room in redis is deleted with it
+func (c *cluster) end(meetingId MeetingId) {
+	if c == nil {
+		return
+	}
+	c.publish(meetingId, &envelope{Kind: kindEnd}, nil)
+	if err := cache.Del(context.Background(), stateKeys(meetingId)...); err != nil {
+		zap.L().Error("delete room state error", zap.Error(err))
+	}
+}
+
+// shareLock descp: share the lock of room changed by event
+func (c *cluster) shareLock(r *Room, event consts.Event) {
+	if c == nil {
+		return
+	}
+	locked := "0"
+	if event == consts.Lock {
+		locked = "1"
+	}
+	c.publish(r.Meeting.Id, &envelope{Kind: kindLock, Event: event}, &write{key: stateKey(r.Meeting.Id), save: map[string]any{"locked": locked}})
+}
+
+// shareBreakouts descp: share the breakouts changed by this node
+func (c *cluster) shareBreakouts(r *Room) {
+	if c == nil {
+		return
+	}
+	layout := r.layout()
+	data, err := jsoniter.Marshal(layout)
+	if err != nil {
+		zap.L().Error("marshal breakouts error", zap.Error(err))
+		return
+	}
+	c.publish(r.Meeting.Id, &envelope{Kind: kindBreakout, Data: data}, &write{key: stateKey(r.Meeting.Id), save: map[string]any{"breakouts": string(data)}})
+}
+
+// wait descp: share the device held in the lobby of this node
+func (c *cluster) wait(r *Room, device *Device) {
+	if c == nil {
+		return
+	}
+	members := []*presence{{Node: c.node, Device: device}}
+	c.publish(r.Meeting.Id, &envelope{Kind: kindWait, Members: members}, &write{key: lobbyKey(r.Meeting.Id), save: presences(members)})
+}
+
+// unwait descp: the device has left the lobby of this node, admitted or not
+func (c *cluster) unwait(r *Room, deviceId DeviceId) {
+	if c == nil {
+		return
+	}
+	c.publish(r.Meeting.Id, &envelope{Kind: kindUnwait, Target: deviceId}, &write{key: lobbyKey(r.Meeting.Id), remove: []string{deviceId}})
+}
+
+// admission descp: ask the node holding the device in its lobby to admit or deny it
+func (c *cluster) admission(r *Room, kind envelopeKind, deviceId DeviceId) {
+	c.publish(r.Meeting.Id, &envelope{Kind: kind, Target: deviceId}, nil)
+}
+
+// changeFloor descp: run the floor script in redis, then apply and share the floor changed, return the version of it
+func (c *cluster) changeFloor(r *Room, script *redis.Script, args ...any) (int64, error) {
+	data, err := cache.Eval(context.Background(), script, []string{floorKey(r.Meeting.Id), stateKey(r.Meeting.Id)}, args...)
+	if err != nil {
+		return 0, err
+	}
+	values, ok := data.([]any)
+	if !ok || len(values) == 0 {
+		return 0, error2.InvalidTypeAssert
+	}
+	version, ok := values[0].(int64)
+	if !ok {
+		return 0, error2.InvalidTypeAssert
+	}
+	if version < 0 {
+		return version, nil
+	}
+
+	state := &floorState{Version: version, Holders: make([]DeviceId, 0, len(values)-1)}
+	for _, v := range values[1:] {
+		if deviceId, ok := v.(string); ok {
+			state.Holders = append(state.Holders, deviceId)
+		}
+	}
+	r.setFloor(state)
+
+	raw, err := jsoniter.Marshal(state)
+	if err != nil {
+		zap.L().Error("marshal floor error", zap.Error(err))
+		return version, nil
+	}
+	c.publish(r.Meeting.Id, &envelope{Kind: kindFloor, Data: raw}, nil)
+	return version, nil
+}
+
+func (c *cluster) grantFloor(r *Room, deviceId DeviceId) (bool, error) {
+	version, err := c.changeFloor(r, grantScript, deviceId, floorLimit(), time.Now().UnixMilli(), presenceTTL().Milliseconds())
+	if err != nil {
+		zap.L().Error("grant floor error", zap.Error(err))
+		return false, err
+	}
+	if version == floorFull {
+		return false, error2.FloorTaken
+	}
+	return version != floorHeld, nil
+}
+
+func (c *cluster) releaseFloor(r *Room, deviceId DeviceId) bool {
+	version, err := c.changeFloor(r, releaseScript, deviceId, presenceTTL().Milliseconds())
+	if err != nil {
+		zap.L().Error("release floor error", zap.Error(err))
+		return false
+	}
+	return version != floorHeld
+}
+
+// count descp: the members of the room on all nodes
+func (c *cluster) count(meetingId MeetingId) int64 {
+	if c == nil {
+		return 0
+	}
+	count, err := cache.HLen(context.Background(), membersKey(meetingId))
+	if err != nil {
+		zap.L().Error("count presence error", zap.Error(err))
+	}
+	return count
+}
+
+func (c *cluster) handle(meetingId MeetingId, payload string) {
+	batch := make([]*envelope, 0)
+	if err := jsoniter.UnmarshalFromString(payload, &batch); err != nil {
+		zap.L().Error("unmarshal envelope error", zap.Error(err))
+		return
+	}
+	r, ok := c.getRoom(meetingId)
+	if !ok {
+		return
+	}
+
+	for _, e := range batch {
+		if e.Node != c.node {
+			c.apply(r, e)
+		}
+	}
+}
+
+func (c *cluster) apply(r *Room, e *envelope) {
+	meetingId := r.Meeting.Id
+	zap.L().Debug("receive envelope", zap.String("meetingId", meetingId), zap.Any("kind", e.Kind), zap.String("node", e.Node))
+
+	switch e.Kind {
+	case kindJoin:
+		for _, p := range e.Members {
+			r.addRemote(p)
+		}
+	case kindLeave:
+		r.dropRemote(e.Target, false)
+	case kindDevice:
+		r.updateDevices(e.Members)
+	case kindSend:
+		if m, ok := r.Members.Get(e.Target); ok && !m.isRemote() {
+			if patchEvents[e.Event] {
+				r.patchLock.Lock()
+				sendPatch(m, r.version.Add(1), e.Event, e.Data)
+				r.patchLock.Unlock()
+				break
+			}
+			sendTo(m, &Message[jsoniter.RawMessage]{Id: m.NextId(), Event: e.Event, Data: e.Data})
+		}
+	case kindQuit:
+		if m, ok := r.Members.Get(e.Target); ok && !m.isRemote() {
+			go m.quit()
+		}
+	case kindEnd:
+		go Global.removeRoom(r, false)
+	case kindLock:
+		r.setLocked(e.Event == consts.Lock)
+	case kindFloor:
+		state := &floorState{}
+		if err := jsoniter.Unmarshal(e.Data, state); err == nil {
+			r.setFloor(state)
+		}
+	case kindBreakout:
+		layout := &breakoutLayout{}
+		if err := jsoniter.Unmarshal(e.Data, layout); err == nil {
+			r.applyBreakouts(layout)
+		}
+	case kindWait:
+		for _, p := range e.Members {
+			r.addRemoteWaiter(p)
+		}
+	case kindUnwait:
+		r.dropRemoteWaiter(e.Target)
+	case kindAdmit, kindDeny:
+		if w, ok := r.Lobby.Get(e.Target); ok && !w.isRemote() {
+			if e.Kind == kindAdmit {
+				go r.admit(e.Target)
+			} else {
+				r.deny(e.Target)
+			}
+		}
+	}
+}
+
+// beat descp: keep the node alive, and clean up the members of the dead nodes in the subscribed rooms
+func (c *cluster) beat() {
+	ctx := context.Background()
+	if err := cache.Set(ctx, nodeKey(c.node), time.Now().Unix(), presenceTTL()); err != nil {
+		zap.L().Error("node heartbeat error", zap.Error(err))
+		return
+	}
+
+	c.lock.Lock()
+	rooms := make([]*Room, 0, len(c.rooms))
+	for _, r := range c.rooms {
+		rooms = append(rooms, r)
+	}
+	c.lock.Unlock()
+
+	alive := make(map[string]bool)
+	isAlive := func(node string) bool {
+		if _, ok := alive[node]; !ok {
+			alive[node] = c.isAlive(node)
+		}
+		return alive[node]
+	}
+	for _, r := range rooms {
+		local := make([]*presence, 0)
+		dead := make([]DeviceId, 0)
+		for _, m := range r.snapshot() {
+			if !m.isRemote() {
+				local = append(local, c.presence(m))
+				continue
+			}
+			if !isAlive(m.node) {
+				dead = append(dead, m.Device.Id)
+			}
+		}
+		deadWaiters := make([]DeviceId, 0)
+		for _, w := range r.remoteWaiters() {
+			if !isAlive(w.node) {
+				deadWaiters = append(deadWaiters, w.Device.Id)
+			}
+		}
+
+		if len(local) > 0 {
+			c.write(&write{key: membersKey(r.Meeting.Id), save: presences(local)})
+		}
+		for _, key := range stateKeys(r.Meeting.Id) {
+			if err := cache.Expire(ctx, key, presenceTTL()); err != nil {
+				zap.L().Error("expire room state error", zap.String("key", key), zap.Error(err))
+			}
+		}
+		if len(dead) > 0 {
+			zap.L().Info("clean up members of dead node", zap.String("meetingId", r.Meeting.Id), zap.Strings("deviceIds", dead))
+			if err := cache.HDel(ctx, membersKey(r.Meeting.Id), dead...); err != nil {
+				zap.L().Error("delete presence error", zap.Error(err))
+			}
+			for _, deviceId := range dead {
+				r.dropRemote(deviceId, true)
+			}
+		}
+		if len(deadWaiters) > 0 {
+			if err := cache.HDel(ctx, lobbyKey(r.Meeting.Id), deadWaiters...); err != nil {
+				zap.L().Error("delete waiter error", zap.Error(err))
+			}
+			for _, deviceId := range deadWaiters {
+				r.dropRemoteWaiter(deviceId)
+			}
+		}
+	}
+}
+
+// stop descp: the node is regarded as dead at once, so the other nodes clean up its members on their next beat
+func (c *cluster) stop() {
+	if c == nil {
+		return
+	}
+	c.flush()
+	if err := cache.Del(context.Background(), nodeKey(c.node)); err != nil {
+		zap.L().Error("delete node error", zap.Error(err))
+	}
+	if err := c.pubsub.Close(); err != nil {
+		zap.L().Error("close pubsub error", zap.Error(err))
+	}
+}
+
+func (c *cluster) isAlive(node string) bool {
+	ok, err := cache.Exists(context.Background(), nodeKey(node))
+	if err != nil {
+		zap.L().Error("check node error", zap.Error(err))
+		return true
+	}
+	return ok
+}
+
+func (m *Member) isRemote() bool {
+	return m.node != ""
+}
+
+func newRemoteMember(p *presence, room *Room) *Member {
+	p.Device.JoinTime = p.JoinTime
+	return &Member{
+		autoIncrId: &atomic.Int32{},
+		outbox:     newOutbox(),
+		Device:     p.Device,
+		Room:       room,
+		node:       p.Node,
+		token:      p.Digest,
+	}
+}
+
+// addRemote descp: a member joined another node, the local member with the same id has been replaced by it
+func (r *Room) addRemote(p *presence) {
+	r.joinLock.Lock()
+	defer r.joinLock.Unlock()
+
+	if r.ended {
+		return
+	}
+
+	old, ok := r.Members.Get(p.Device.Id)
+	member := newRemoteMember(p, r)
+	r.Members.Set(member.Device.Id, member)
+	if ok {
+		r.leave(old)
+		r.negotiations.forget(old.Device.Id)
+	}
+	r.enter(member)
+	r.wake()
+
+	if ok && !old.isRemote() {
+		old.sessionLock.Lock()
+		old.quitted = true
+		old.stopTimers()
+		old.sessionLock.Unlock()
+		old.outbox.stop()
+		old.Conn.Close()
+	}
+}
+
+// dropRemote descp: remove the remote member, notify is true when its node is dead and can't tell the peers
+func (r *Room) dropRemote(deviceId DeviceId, notify bool) {
+	member, ok := r.Members.Get(deviceId)
+	if !ok || !member.isRemote() {
+		return
+	}
+	r.Members.Delete(deviceId)
+	r.negotiations.forget(deviceId)
+	peers := r.leave(member)
+
+	if notify {
+		patch(r, peers, consts.Leave, deviceId, remoteIds(peers)...)
+		// descp only the node releasing the floor in redis tells, so it tells the members of all nodes
+		if r.releaseFloor(deviceId) {
+			patch(r, r.Members, consts.Floor, r.getFloor())
+		}
+		if changed := r.handOverHost(member.device()); len(changed) > 0 {
+			patch(r, r.Members, consts.RoleChange, changed, remoteIds(r.Members)...)
+			Global.cluster.sync(r, changed...)
+		}
+	}
+
+	r.idle()
+}
+
+// updateDevices descp: apply the changes of devices made on other nodes
+func (r *Room) updateDevices(members []*presence) {
+	r.roleLock.Lock()
+	defer r.roleLock.Unlock()
+
+	for _, p := range members {
+		m, ok := r.Members.Get(p.Device.Id)
+		if !ok {
+			continue
+		}
+		m.deviceLock.Lock()
+		m.Device.Nickname = p.Device.Nickname
+		m.Device.Role = p.Device.Role
+		m.Device.MediaState = p.Device.MediaState
+		m.deviceLock.Unlock()
+	}
+}
+
+// remoteIds descp: the remote members are told by their own nodes
+func remoteIds(members Members) []DeviceId {
+	ids := make([]DeviceId, 0)
+	members.Range(func(deviceId DeviceId, member *Member) {
+		if member.isRemote() {
+			ids = append(ids, deviceId)
+		}
+	})
+	return ids
+}
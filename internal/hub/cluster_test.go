@@ -0,0 +1,82 @@
+package hub
+
+import (
+	"reflect"
+	"testing"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+)
+
+func TestRoom_ApplyBreakouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   *breakoutLayout
+		wantMain []DeviceId
+		wantRoom map[string][]DeviceId
+	}{
+		{
+			name:     "assigned",
+			layout:   &breakoutLayout{Rooms: []string{"x", "y"}, Assignments: map[DeviceId]string{"a": "x", "b": "y", "c": "z"}},
+			wantMain: []DeviceId{"c"},
+			wantRoom: map[string][]DeviceId{"x": {"a"}, "y": {"b"}},
+		},
+		{
+			name:     "closed",
+			layout:   &breakoutLayout{},
+			wantMain: []DeviceId{"a", "b", "c"},
+			wantRoom: map[string][]DeviceId{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(&model.Meeting{})
+			for _, deviceId := range []DeviceId{"a", "b", "c"} {
+				addMember(t, r, deviceId, consts.Participant)
+			}
+			if err := r.openBreakouts(&BreakoutOpenOption{Rooms: []string{"x"}, Assignments: map[DeviceId]string{"c": "x"}}); err != nil {
+				t.Fatal(err)
+			}
+
+			r.applyBreakouts(tt.layout)
+
+			if got := memberIds(r.Main); !reflect.DeepEqual(got, tt.wantMain) {
+				t.Errorf("applyBreakouts() main = %v, want %v", got, tt.wantMain)
+			}
+			if len(r.breakouts) != len(tt.wantRoom) {
+				t.Errorf("applyBreakouts() breakouts = %v, want %v", len(r.breakouts), len(tt.wantRoom))
+			}
+			for name, want := range tt.wantRoom {
+				breakout, ok := r.breakouts[name]
+				if !ok {
+					t.Errorf("applyBreakouts() want breakout %v", name)
+					continue
+				}
+				if got := memberIds(breakout.Members); !reflect.DeepEqual(got, want) {
+					t.Errorf("applyBreakouts() breakout %v = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoom_SetFloor(t *testing.T) {
+	r := newTestRoom(&model.Meeting{})
+
+	r.setFloor(&floorState{Version: 2, Holders: []DeviceId{"a", "b"}})
+	r.setFloor(&floorState{Version: 1, Holders: []DeviceId{"a"}})
+
+	if got := r.getFloor(); !reflect.DeepEqual(got, []DeviceId{"a", "b"}) {
+		t.Errorf("setFloor() floor = %v, want the newer version kept", got)
+	}
+}
+
+// memberIds descp: the ids of a, b and c in members, in order
+func memberIds(members Members) []DeviceId {
+	ids := make([]DeviceId, 0, members.Len())
+	for _, deviceId := range []DeviceId{"a", "b", "c"} {
+		if _, ok := members.Get(deviceId); ok {
+			ids = append(ids, deviceId)
+		}
+	}
+	return ids
+}
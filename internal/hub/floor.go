@@ -0,0 +1,129 @@
+package hub
+
+import (
+	"volo_meeting/consts"
+	error2 "volo_meeting/lib/error"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// floorLimit descp: at most floorLimit devices are allowed to share screen at the same time
+func floorLimit() int {
+	limit := viper.GetInt("meeting.floor_limit")
+	if limit <= 0 {
+		return consts.DefaultFloorLimit
+	}
+	return limit
+}
+
+// floorState descp: the floor holders in redis, Version is increased by every change of them
+type floorState struct {
+	Version int64      `json:"version"`
+	Holders []DeviceId `json:"holders"`
+}
+
+// setFloor descp: apply the floor changed in redis, an older version arriving late is ignored
+func (r *Room) setFloor(state *floorState) {
+	r.floorLock.Lock()
+	defer r.floorLock.Unlock()
+
+	if state.Version < r.floorVersion {
+		return
+	}
+	r.floorVersion = state.Version
+	r.floor = state.Holders
+}
+
+func (r *Room) hasFloor(deviceId DeviceId) bool {
+	r.floorLock.Lock()
+	defer r.floorLock.Unlock()
+
+	for _, id := range r.floor {
+		if id == deviceId {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Room) getFloor() []DeviceId {
+	r.floorLock.Lock()
+	defer r.floorLock.Unlock()
+
+	holders := make([]DeviceId, len(r.floor))
+	copy(holders, r.floor)
+	return holders
+}
+
+// grantFloor descp: return false if the device already holds the floor.
+// the floor is granted in redis when clustered, so the limit holds across nodes
+func (r *Room) grantFloor(deviceId DeviceId) (bool, error) {
+	if Global.cluster != nil {
+		return Global.cluster.grantFloor(r, deviceId)
+	}
+
+	r.floorLock.Lock()
+	defer r.floorLock.Unlock()
+
+	for _, id := range r.floor {
+		if id == deviceId {
+			return false, nil
+		}
+	}
+	if len(r.floor) >= floorLimit() {
+		return false, error2.FloorTaken
+	}
+
+	r.floor = append(r.floor, deviceId)
+	return true, nil
+}
+
+// releaseFloor descp: return false if the device doesn't hold the floor
+func (r *Room) releaseFloor(deviceId DeviceId) bool {
+	if Global.cluster != nil {
+		return Global.cluster.releaseFloor(r, deviceId)
+	}
+
+	r.floorLock.Lock()
+	defer r.floorLock.Unlock()
+
+	for i, id := range r.floor {
+		if id == deviceId {
+			r.floor = append(r.floor[:i], r.floor[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Member) requestFloor(messageId int32) {
+	granted, err := m.Room.grantFloor(m.Device.Id)
+	if err != nil {
+		m.Conn.Emit(consts.Err, err, messageId)
+		return
+	}
+	if !granted {
+		return
+	}
+
+	zap.L().Debug("grant floor", zap.String("deviceId", m.Device.Id))
+	patch(m.Room, m.Room.Members, consts.Floor, m.Room.getFloor())
+}
+
+// dropFloor descp: release the floor and stop the screen sharing of member, return false if it doesn't hold the floor
+func (m *Member) dropFloor() bool {
+	if !m.Room.releaseFloor(m.Device.Id) {
+		return false
+	}
+
+	zap.L().Debug("drop floor", zap.String("deviceId", m.Device.Id))
+
+	stop := false
+	if changed := m.applyMedia(&MediaDiff{ScreenSharing: &stop}); changed != nil {
+		patch(m.Room, m.peers(), consts.Media, changed)
+		Global.cluster.sync(m.Room, m.device())
+	}
+	patch(m.Room, m.Room.Members, consts.Floor, m.Room.getFloor())
+	return true
+}
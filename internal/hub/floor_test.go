@@ -0,0 +1,57 @@
+package hub
+
+import (
+	"testing"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestMember_FloorRevoke(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        consts.MemberRole
+		targetRole  consts.MemberRole
+		holding     bool
+		wantRevoked bool
+	}{
+		{name: "host revokes participant", role: consts.Host, targetRole: consts.Participant, holding: true, wantRevoked: true},
+		{name: "co-host revokes host", role: consts.CoHost, targetRole: consts.Host, holding: true, wantRevoked: true},
+		{name: "target not holding", role: consts.Host, targetRole: consts.Participant, holding: false},
+		{name: "participant can't revoke", role: consts.Participant, targetRole: consts.Participant, holding: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(&model.Meeting{})
+			moderator, moderatorClient := addMember(t, r, "a", tt.role)
+			addMember(t, r, "b", tt.targetRole)
+			moderator.setupEmitter()
+			if tt.holding {
+				if _, err := r.grantFloor("b"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			data, _ := jsoniter.Marshal(map[string]any{"id": "b"})
+			moderator.moderate(&Message[jsoniter.RawMessage]{7, consts.FloorRevoke, data})
+
+			if !tt.wantRevoked {
+				if message := readMessage[*jsoniter.RawMessage](t, moderatorClient, consts.Error); message.Id != 7 {
+					t.Errorf("moderate() error id = %v, want 7", message.Id)
+				}
+				if r.hasFloor("b") != tt.holding {
+					t.Errorf("hasFloor() = %v, want %v", r.hasFloor("b"), tt.holding)
+				}
+				return
+			}
+
+			if message := readMessage[[]DeviceId](t, moderatorClient, consts.Floor); len(message.Data) != 0 {
+				t.Errorf("moderate() floor = %v, want empty", message.Data)
+			}
+			if r.hasFloor("b") {
+				t.Error("moderate() want the floor revoked from b")
+			}
+		})
+	}
+}
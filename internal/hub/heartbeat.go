@@ -0,0 +1,28 @@
+package hub
+
+import (
+	"time"
+	"volo_meeting/consts"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Heartbeat descp: consts.Ping carries the client Timestamp, consts.Pong echoes it with the server
+// Receive and Send time, all in unix milliseconds, so that clients can measure rtt and clock offset
+type Heartbeat struct {
+	Timestamp int64 `json:"timestamp"`
+	Receive   int64 `json:"receive,omitempty"`
+	Send      int64 `json:"send,omitempty"`
+}
+
+// pong descp: any message received has pushed the read deadline of ws.Conn, so a ping keeps the conn alive
+// even if the proxy strips control frames
+func pong(message *Message[jsoniter.RawMessage]) *Message[*Heartbeat] {
+	receive := time.Now().UnixMilli()
+	heartbeat := &Heartbeat{}
+	_ = jsoniter.Unmarshal(message.Data, heartbeat)
+
+	heartbeat.Receive = receive
+	heartbeat.Send = time.Now().UnixMilli()
+	return &Message[*Heartbeat]{message.Id, consts.Pong, heartbeat}
+}
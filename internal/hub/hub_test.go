@@ -0,0 +1,80 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+	"volo_meeting/lib/ws"
+
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// newConn descp: return the server side Conn and the client side socket
+func newConn(t *testing.T) (*ws.Conn, *websocket.Conn) {
+	conns := make(chan *ws.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		socket, err := ws.Upgrade(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- ws.NewConn(socket)
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	conn := <-conns
+	t.Cleanup(conn.Close)
+	return conn, client
+}
+
+// readMessage descp: read the next message of the event from client, skipping the others
+func readMessage[T any](t *testing.T, client *websocket.Conn, event consts.Event) *Message[T] {
+	t.Helper()
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %v: %v", event, err)
+		}
+		raw := &Message[jsoniter.RawMessage]{}
+		if err = jsoniter.Unmarshal(data, raw); err != nil {
+			t.Fatal(err)
+		}
+		if raw.Event != event {
+			continue
+		}
+		message := &Message[T]{}
+		if err = jsoniter.Unmarshal(data, message); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+}
+
+// newTestRoom descp: a room of a meeting kept in memory only
+func newTestRoom(meeting *model.Meeting) *Room {
+	if meeting.Id == "" {
+		meeting.Id = "meeting"
+	}
+	return newRoom(meeting)
+}
+
+// addMember descp: put a member with a real conn into the room, skipping the consts.Join flow
+func addMember(t *testing.T, r *Room, deviceId DeviceId, role consts.MemberRole) (*Member, *websocket.Conn) {
+	conn, client := newConn(t)
+	member := newMember(&Device{Id: deviceId, Nickname: deviceId, Role: role}, conn, r)
+	r.Members.Set(deviceId, member)
+	r.enter(member)
+	return member, client
+}
@@ -2,11 +2,13 @@ package hub
 
 import (
 	"errors"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"volo_meeting/consts"
 	"volo_meeting/internal/model"
 	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/ice"
 	"volo_meeting/lib/tsmap"
 	"volo_meeting/lib/ws"
 )
@@ -15,6 +17,29 @@ var (
 	Global = newHub()
 )
 
+func Init() {
+	Global.candidates = candidatePolicies()
+
+	if viper.GetBool("cluster.enabled") {
+		switch viper.GetString("cluster.mode") {
+		case consts.AffinityMode:
+			Global.registry = newRegistry()
+			Global.registry.start()
+		default:
+			Global.cluster = newCluster()
+			Global.cluster.start()
+		}
+	}
+
+	go expireMeetings()
+}
+
+// Close descp: leave the cluster, do nothing when running as a single node
+func Close() {
+	Global.cluster.stop()
+	Global.registry.stop()
+}
+
 func newHub() *hub {
 	return &hub{
 		rooms: tsmap.New[MeetingId, *Room](),
@@ -24,7 +49,10 @@ func newHub() *hub {
 type MeetingId = string
 
 type hub struct {
-	rooms tsmap.TSMap[MeetingId, *Room]
+	rooms      tsmap.TSMap[MeetingId, *Room]
+	cluster    *cluster  // descp nil means not in fan-out mode
+	registry   *registry // descp nil means not in affinity mode
+	candidates ice.Chain // descp the candidate policies of all members
 }
 
 func (h *hub) GetRoom(meetingId MeetingId) (*Room, error) {
@@ -43,17 +71,26 @@ func (h *hub) GetRoom(meetingId MeetingId) (*Room, error) {
 		return nil, error2.New(consts.SqlError, err)
 	}
 
-	if err = meeting.StartNow(model.Instance()); err != nil {
-		return nil, error2.New(consts.SqlError, err)
+	// descp the meeting may have been started on another node
+	if meeting.StartTime == nil {
+		if err = meeting.StartNow(model.Instance()); err != nil {
+			return nil, error2.New(consts.SqlError, err)
+		}
 	}
 
-	room = newRoom(meeting)
-	h.rooms.Set(meetingId, room)
+	// descp only the room set first is started, the others created by concurrent joins are dropped
+	room, ok = h.rooms.GetOrSet(meetingId, newRoom(meeting))
+	if ok {
+		return room, nil
+	}
+	h.cluster.attach(room)
+	room.idle()
+	room.schedule()
 
 	return room, nil
 }
 
-func (h *hub) JoinRoom(meetingId MeetingId, device *Device, conn *ws.Conn) {
+func (h *hub) JoinRoom(meetingId MeetingId, device *Device, conn *ws.Conn, token string) {
 	room, err := h.GetRoom(meetingId)
 	if err != nil {
 		zap.L().Error("get room error", zap.Error(err))
@@ -61,25 +98,64 @@ func (h *hub) JoinRoom(meetingId MeetingId, device *Device, conn *ws.Conn) {
 		return
 	}
 
-	room.Join(device, conn)
+	if room.needAdmission(device, token) {
+		if errId, err := room.checkJoin(device); err != nil {
+			reject(device, conn, errId, err)
+			return
+		}
+		room.wait(device, conn)
+		return
+	}
+
+	room.Join(device, conn, token)
 }
 
+// RemoveRoom descp: end the meeting on every node
 func (h *hub) RemoveRoom(meetingId MeetingId) error {
-	room, err := h.GetRoom(meetingId)
-	if err != nil {
-		return err
+	room, ok := h.rooms.Get(meetingId)
+	if !ok {
+		return error2.NotFound("room not found")
+	}
+	h.removeRoom(room, true)
+	return nil
+}
+
+// removeRoom descp: end the room and remove it, announce is false when the meeting has been ended by another node
+func (h *hub) removeRoom(room *Room, announce bool) {
+	if room.end() {
+		h.closeRoom(room, announce)
+	}
+}
+
+// closeRoom descp: room must have been marked ended by the caller. a room which is no longer the one in the map
+// is left alone, so the meeting of the room taking its place isn't ended by it
+func (h *hub) closeRoom(room *Room, announce bool) {
+	meetingId := room.Meeting.Id
+	if current, ok := h.rooms.Get(meetingId); !ok || current != room {
+		return
 	}
 	defer func() {
-		err = room.Meeting.EndNow(model.Instance())
+		err := room.Meeting.EndNow(model.Instance())
 		if err != nil {
 			zap.L().Error("end meeting error", zap.Error(err))
 		}
 	}()
 
+	if announce {
+		h.cluster.end(meetingId)
+	}
+	h.cluster.detach(meetingId)
+	h.registry.release(meetingId)
+
 	room.Members.Range(func(key MeetingId, value *Member) {
-		go value.Conn.Emit(consts.Close)
+		if !value.isRemote() {
+			go value.quit()
+		}
+	})
+	room.Lobby.Range(func(key DeviceId, value *waiter) {
+		if !value.isRemote() {
+			go value.Conn.Emit(consts.Close)
+		}
 	})
 	h.rooms.Delete(meetingId)
-
-	return nil
 }
@@ -0,0 +1,103 @@
+package hub
+
+import (
+	"time"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func idleTimeout() time.Duration {
+	if timeout := viper.GetInt("meeting.idle_timeout"); timeout > 0 {
+		return time.Duration(timeout) * time.Second
+	}
+	return consts.DefaultIdleTimeout
+}
+
+func unusedExpire() time.Duration {
+	if expire := viper.GetInt("meeting.unused_expire"); expire > 0 {
+		return time.Duration(expire) * time.Second
+	}
+	return consts.DefaultUnusedExpire
+}
+
+// idle descp: start the idle countdown once the room has nobody in it or its lobby
+func (r *Room) idle() {
+	r.joinLock.Lock()
+	defer r.joinLock.Unlock()
+
+	if r.ended || r.Members.Len() > 0 || r.Lobby.Len() > 0 {
+		return
+	}
+	if r.idleTimer != nil {
+		r.idleTimer.Stop()
+	}
+	r.idleTimer = time.AfterFunc(idleTimeout(), r.expire)
+}
+
+// wake descp: caller must hold joinLock
+func (r *Room) wake() {
+	if r.idleTimer != nil {
+		r.idleTimer.Stop()
+		r.idleTimer = nil
+	}
+}
+
+// expire descp: end the meeting and remove the room if still nobody comes back.
+// the room is marked ended under joinLock, so a join right after the check is refused instead of ended with the room
+func (r *Room) expire() {
+	if Global.cluster.count(r.Meeting.Id) > 0 {
+		return
+	}
+
+	r.joinLock.Lock()
+	ended := r.Members.Len() == 0 && r.Lobby.Len() == 0 && r.markEnded()
+	r.joinLock.Unlock()
+	if !ended {
+		return
+	}
+
+	zap.L().Debug("room idle timeout", zap.String("meetingId", r.Meeting.Id))
+
+	Global.closeRoom(r, true)
+}
+
+// end descp: mark the room ended, so no one can join it any more, return false if it has been ended
+func (r *Room) end() bool {
+	r.joinLock.Lock()
+	defer r.joinLock.Unlock()
+
+	return r.markEnded()
+}
+
+// markEnded descp: caller must hold joinLock
+func (r *Room) markEnded() bool {
+	if r.ended {
+		return false
+	}
+	r.ended = true
+	r.wake()
+	if r.scheduleTimer != nil {
+		r.scheduleTimer.Stop()
+	}
+	return true
+}
+
+// expireMeetings descp: end the meetings created but never joined for unusedExpire
+func expireMeetings() {
+	ticker := time.NewTicker(consts.MeetingSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := model.ExpireUnstarted(model.Instance(), time.Now().Add(-unusedExpire()))
+		if err != nil {
+			zap.L().Error("expire unstarted meetings error", zap.Error(err))
+			continue
+		}
+		if count > 0 {
+			zap.L().Debug("expire unstarted meetings", zap.Int64("count", count))
+		}
+	}
+}
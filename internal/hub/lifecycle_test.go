@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"testing"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestRoom_Expire(t *testing.T) {
+	tests := []struct {
+		name      string
+		stale     bool // descp another room of the meeting has taken its place
+		members   []DeviceId
+		wantEnded bool
+	}{
+		{name: "room with members", members: []DeviceId{"a"}, wantEnded: false},
+		{name: "stale room", stale: true, wantEnded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global := Global
+			Global = newHub()
+			t.Cleanup(func() { Global = global })
+
+			r := newTestRoom(&model.Meeting{})
+			current := r
+			if tt.stale {
+				current = newTestRoom(&model.Meeting{})
+			}
+			Global.rooms.Set(r.Meeting.Id, current)
+			for _, deviceId := range tt.members {
+				addMember(t, r, deviceId, consts.Participant)
+			}
+
+			r.expire()
+
+			if r.ended != tt.wantEnded {
+				t.Errorf("expire() ended = %v, want %v", r.ended, tt.wantEnded)
+			}
+			if got, ok := Global.rooms.Get(r.Meeting.Id); !ok || got != current {
+				t.Error("expire() want the current room of the meeting kept")
+			}
+			if tt.stale && current.ended {
+				t.Error("expire() want the current room of the meeting not ended")
+			}
+		})
+	}
+}
+
+func TestRoom_JoinEnded(t *testing.T) {
+	r := newTestRoom(&model.Meeting{})
+	if !r.end() {
+		t.Fatal("end() = false, want true")
+	}
+	if r.end() {
+		t.Error("end() = true, want false once ended")
+	}
+
+	conn, client := newConn(t)
+	if member := r.join(&Device{Id: "a"}, conn, ""); member != nil {
+		t.Error("join() want refused by the ended room")
+	}
+	if message := readMessage[*jsoniter.RawMessage](t, client, consts.Error); message.Id != consts.WrongMeeting {
+		t.Errorf("join() error id = %v, want %v", message.Id, consts.WrongMeeting)
+	}
+	if _, ok := r.Members.Get("a"); ok {
+		t.Error("join() want a not in the ended room")
+	}
+}
@@ -0,0 +1,205 @@
+package hub
+
+import (
+	"fmt"
+	"volo_meeting/consts"
+	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/ws"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+// waiter descp: a device held in the lobby, its conn is listened but not joined yet.
+// the ones held by other nodes are kept as remote waiters, admitted or denied by their own nodes
+type waiter struct {
+	Device *Device
+	Conn   *ws.Conn
+
+	node string // descp non-empty means a remote waiter held by that node, whose Conn is nil
+
+	onMessage func(data []byte)
+	onClose   func()
+}
+
+func (w *waiter) isRemote() bool {
+	return w.node != ""
+}
+
+// needAdmission descp: the proved creator and the members resuming with their session token skip the lobby,
+// and the first joiner of a meeting without creator becomes host directly
+func (r *Room) needAdmission(device *Device, token string) bool {
+	if !r.Meeting.Lobby || device.Creator {
+		return false
+	}
+
+	if member, ok := r.Members.Get(device.Id); ok && member.owns(token) {
+		return false
+	}
+
+	return r.Members.Len() > 0 || r.Meeting.HostId != ""
+}
+
+// wait descp: hold the device in the lobby until host or co-host admits or denies it
+func (r *Room) wait(device *Device, conn *ws.Conn) {
+	w := &waiter{Device: device, Conn: conn}
+	w.onMessage = func(data []byte) {
+		message := &Message[jsoniter.RawMessage]{}
+		if err := jsoniter.Unmarshal(data, message); err != nil {
+			return
+		}
+		switch message.Event {
+		case consts.Leave:
+			conn.Emit(consts.Close)
+		case consts.Ping:
+			conn.Send(pong(message))
+		}
+	}
+	w.onClose = func() {
+		zap.L().Debug("receive close in lobby", zap.String("deviceId", device.Id))
+
+		if current, ok := r.Lobby.Get(device.Id); ok && current == w {
+			r.Lobby.Delete(device.Id)
+			Global.cluster.unwait(r, device.Id)
+			r.notifyLobby()
+			r.idle()
+		}
+		conn.CloseAfterFlush()
+	}
+
+	if old, ok := r.Lobby.Get(device.Id); ok && !old.isRemote() {
+		old.Conn.Emit(consts.Close)
+	}
+
+	conn.On(consts.Message, w.onMessage)
+	conn.On(consts.Close, w.onClose)
+	r.Lobby.Set(device.Id, w)
+	Global.cluster.wait(r, device)
+
+	conn.Send(&Message[*Device]{1, consts.Waiting, device})
+	r.notifyLobby()
+}
+
+// admit descp: move the device from lobby into the room through the normal consts.Join flow,
+// a remote waiter is admitted by its own node
+func (r *Room) admit(deviceId DeviceId) bool {
+	w, ok := r.Lobby.Get(deviceId)
+	if !ok {
+		return false
+	}
+	r.Lobby.Delete(deviceId)
+	if w.isRemote() {
+		Global.cluster.admission(r, kindAdmit, deviceId)
+		r.notifyLobby()
+		return true
+	}
+
+	w.Conn.Off(consts.Message, w.onMessage)
+	w.Conn.Off(consts.Close, w.onClose)
+	Global.cluster.unwait(r, deviceId)
+
+	r.Join(w.Device, w.Conn, "")
+	r.notifyLobby()
+
+	return true
+}
+
+// deny descp: send error to the held device and close it, the error is written before the conn is closed.
+// a remote waiter is denied by its own node
+func (r *Room) deny(deviceId DeviceId) bool {
+	w, ok := r.Lobby.Get(deviceId)
+	if !ok {
+		return false
+	}
+	if w.isRemote() {
+		r.Lobby.Delete(deviceId)
+		Global.cluster.admission(r, kindDeny, deviceId)
+		r.notifyLobby()
+		return true
+	}
+
+	w.Conn.Send(&Message[error]{consts.DeniedByHost, consts.Error, error2.DeniedByHost})
+	w.Conn.Emit(consts.Close)
+
+	return true
+}
+
+func (r *Room) getWaitingDevices() []*Device {
+	devices := make([]*Device, 0, r.Lobby.Len())
+	r.Lobby.Range(func(key DeviceId, value *waiter) {
+		devices = append(devices, value.Device)
+	})
+
+	return devices
+}
+
+// remoteWaiters descp: copy the remote waiters out, so that the caller can wait for redis while iterating
+func (r *Room) remoteWaiters() []*waiter {
+	waiters := make([]*waiter, 0)
+	r.Lobby.Range(func(key DeviceId, value *waiter) {
+		if value.isRemote() {
+			waiters = append(waiters, value)
+		}
+	})
+	return waiters
+}
+
+// addRemoteWaiter descp: a device held in the lobby of another node, the local waiter with the same id is replaced by it
+func (r *Room) addRemoteWaiter(p *presence) {
+	old, ok := r.Lobby.Get(p.Device.Id)
+	r.Lobby.Set(p.Device.Id, &waiter{Device: p.Device, node: p.Node})
+	if ok && !old.isRemote() {
+		old.Conn.Emit(consts.Close)
+	}
+	r.notifyLobby()
+}
+
+// dropRemoteWaiter descp: the remote waiter has left the lobby of its node
+func (r *Room) dropRemoteWaiter(deviceId DeviceId) {
+	w, ok := r.Lobby.Get(deviceId)
+	if !ok || !w.isRemote() {
+		return
+	}
+	r.Lobby.Delete(deviceId)
+	r.notifyLobby()
+	r.idle()
+}
+
+// notifyLobby descp: send the whole waiting list to host and co-host
+func (r *Room) notifyLobby() {
+	devices := r.getWaitingDevices()
+	r.Members.Range(func(deviceId DeviceId, member *Member) {
+		sendTo(member, &Message[[]*Device]{member.NextId(), consts.Lobby, devices})
+	}, func(deviceId DeviceId, member *Member) bool {
+		return !member.device().canModerate()
+	})
+}
+
+// admission descp: handle consts.Admit and consts.Deny from host or co-host
+func (m *Member) admission(message *Message[jsoniter.RawMessage]) {
+	if !m.device().canModerate() {
+		m.Conn.Emit(consts.Err, error2.NoPermission, message.Id)
+		return
+	}
+
+	target := &Target{}
+	err := jsoniter.Unmarshal(message.Data, target)
+	if err != nil {
+		zap.L().Error("unmarshal error", zap.Error(err))
+		sendTo(m, &Message[error]{message.Id, consts.Error, error2.New(consts.MarshalError, err)})
+		return
+	}
+
+	zap.L().Debug("admission", zap.String("deviceId", m.Device.Id), zap.Any("event", message.Event), zap.Any("target", target))
+
+	ok := false
+	switch message.Event {
+	case consts.Admit:
+		ok = m.Room.admit(target.Id)
+	case consts.Deny:
+		ok = m.Room.deny(target.Id)
+	}
+	if !ok {
+		m.Conn.Emit(consts.Err, error2.NotFound(fmt.Sprintf("device not in lobby: %v", target.Id)), message.Id)
+	}
+}
@@ -0,0 +1,140 @@
+package hub
+
+import (
+	"testing"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestRoom_NeedAdmission(t *testing.T) {
+	tests := []struct {
+		name     string
+		meeting  *model.Meeting
+		members  []DeviceId
+		deviceId DeviceId
+		creator  bool
+		ownToken bool // descp join with the session token of the member with the same id
+		want     bool
+	}{
+		{name: "no lobby", meeting: &model.Meeting{}, members: []DeviceId{"a"}, deviceId: "b", want: false},
+		{name: "creator", meeting: &model.Meeting{Lobby: true, HostId: "b"}, members: []DeviceId{"a"}, deviceId: "b", creator: true, want: false},
+		{name: "host id spoofed", meeting: &model.Meeting{Lobby: true, HostId: "b"}, members: []DeviceId{"a"}, deviceId: "b", want: true},
+		{name: "first joiner without creator", meeting: &model.Meeting{Lobby: true}, deviceId: "b", want: false},
+		{name: "first joiner with creator", meeting: &model.Meeting{Lobby: true, HostId: "a"}, deviceId: "b", want: true},
+		{name: "late joiner", meeting: &model.Meeting{Lobby: true}, members: []DeviceId{"a"}, deviceId: "b", want: true},
+		{name: "resuming member", meeting: &model.Meeting{Lobby: true}, members: []DeviceId{"a", "b"}, deviceId: "b", ownToken: true, want: false},
+		{name: "member id spoofed", meeting: &model.Meeting{Lobby: true}, members: []DeviceId{"a", "b"}, deviceId: "b", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(tt.meeting)
+			token := ""
+			for _, deviceId := range tt.members {
+				member, _ := addMember(t, r, deviceId, consts.Participant)
+				if deviceId == tt.deviceId && tt.ownToken {
+					token = member.token
+				}
+			}
+			if got := r.needAdmission(&Device{Id: tt.deviceId, Creator: tt.creator}, token); got != tt.want {
+				t.Errorf("needAdmission() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHub_JoinRoom_Lobby(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostId    DeviceId
+		role      consts.MemberRole
+		locked    bool
+		wantErrId consts.ErrorId // descp 0 means held in the lobby
+	}{
+		{name: "held and denied by host", role: consts.Host},
+		{name: "host id spoofed, held and denied", hostId: "b", role: consts.Host},
+		{name: "held and denied by co-host", role: consts.CoHost},
+		{name: "participant can't deny", role: consts.Participant},
+		{name: "locked room", role: consts.Host, locked: true, wantErrId: consts.LockedRoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHub()
+			r := newTestRoom(&model.Meeting{Lobby: true, HostId: tt.hostId})
+			h.rooms.Set(r.Meeting.Id, r)
+			moderator, moderatorClient := addMember(t, r, "a", tt.role)
+			moderator.setupEmitter()
+			r.setLocked(tt.locked)
+
+			conn, client := newConn(t)
+			h.JoinRoom(r.Meeting.Id, &Device{Id: "b"}, conn, "")
+
+			if tt.wantErrId != 0 {
+				if message := readMessage[*jsoniter.RawMessage](t, client, consts.Error); message.Id != tt.wantErrId {
+					t.Errorf("JoinRoom() error id = %v, want %v", message.Id, tt.wantErrId)
+				}
+				if _, ok := r.Lobby.Get("b"); ok {
+					t.Error("JoinRoom() want b not held in the lobby")
+				}
+				return
+			}
+
+			if message := readMessage[*Device](t, client, consts.Waiting); message.Data.Id != "b" {
+				t.Errorf("JoinRoom() waiting = %v, want b", message.Data.Id)
+			}
+			if _, ok := r.Lobby.Get("b"); !ok {
+				t.Fatal("JoinRoom() want b held in the lobby")
+			}
+
+			data, _ := jsoniter.Marshal(map[string]any{"id": "b"})
+			moderator.admission(&Message[jsoniter.RawMessage]{5, consts.Deny, data})
+
+			if tt.role == consts.Participant {
+				if message := readMessage[*jsoniter.RawMessage](t, moderatorClient, consts.Error); message.Id != 5 {
+					t.Errorf("admission() error id = %v, want 5", message.Id)
+				}
+				if _, ok := r.Lobby.Get("b"); !ok {
+					t.Error("admission() want b still held in the lobby")
+				}
+				return
+			}
+
+			if message := readMessage[[]*Device](t, moderatorClient, consts.Lobby); len(message.Data) != 1 || message.Data[0].Id != "b" {
+				t.Errorf("lobby = %v, want b waiting", message.Data)
+			}
+			if message := readMessage[*jsoniter.RawMessage](t, client, consts.Error); message.Id != consts.DeniedByHost {
+				t.Errorf("admission() error id = %v, want %v", message.Id, consts.DeniedByHost)
+			}
+			if _, _, err := client.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("ReadMessage() error = %v, want normal closure after deny", err)
+			}
+			if _, ok := r.Lobby.Get("b"); ok {
+				t.Error("admission() want b removed from the lobby")
+			}
+		})
+	}
+}
+
+func TestRoom_RemoteWaiter(t *testing.T) {
+	r := newTestRoom(&model.Meeting{Lobby: true})
+	conn, client := newConn(t)
+	r.wait(&Device{Id: "b"}, conn)
+	readMessage[*Device](t, client, consts.Waiting)
+
+	// descp b moved to the lobby of another node, the local one is closed
+	r.addRemoteWaiter(&presence{Node: "other", Device: &Device{Id: "b"}})
+
+	if _, _, err := client.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("ReadMessage() error = %v, want normal closure", err)
+	}
+	if w, ok := r.Lobby.Get("b"); !ok || !w.isRemote() {
+		t.Fatal("addRemoteWaiter() want b held by the other node")
+	}
+
+	r.dropRemoteWaiter("b")
+	if _, ok := r.Lobby.Get("b"); ok {
+		t.Error("dropRemoteWaiter() want b removed from the lobby")
+	}
+}
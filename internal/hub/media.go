@@ -0,0 +1,76 @@
+package hub
+
+import (
+	"volo_meeting/consts"
+	error2 "volo_meeting/lib/error"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+// MediaState descp: owned by server, clients change it by consts.Media and never trust each other
+type MediaState struct {
+	AudioMuted    bool `json:"audio_muted"`
+	VideoMuted    bool `json:"video_muted"`
+	ScreenSharing bool `json:"screen_sharing"`
+}
+
+// MediaDiff descp: nil field means unchanged
+type MediaDiff struct {
+	Id            DeviceId `json:"id"`
+	AudioMuted    *bool    `json:"audio_muted,omitempty"`
+	VideoMuted    *bool    `json:"video_muted,omitempty"`
+	ScreenSharing *bool    `json:"screen_sharing,omitempty"`
+}
+
+// updateMedia descp: apply the diff to Device.MediaState and broadcast the really changed fields
+func (m *Member) updateMedia(message *Message[jsoniter.RawMessage]) {
+	diff := &MediaDiff{}
+	err := jsoniter.Unmarshal(message.Data, diff)
+	if err != nil {
+		zap.L().Error("unmarshal error", zap.Error(err))
+		sendTo(m, &Message[error]{message.Id, consts.Error, error2.New(consts.MarshalError, err)})
+		return
+	}
+
+	if diff.ScreenSharing != nil && *diff.ScreenSharing && !m.Room.hasFloor(m.Device.Id) {
+		m.Conn.Emit(consts.Err, error2.NoPermission, message.Id)
+		return
+	}
+
+	changed := m.applyMedia(diff)
+	if changed == nil {
+		return
+	}
+
+	zap.L().Debug("update media", zap.String("deviceId", m.Device.Id), zap.Any("diff", changed))
+
+	patch(m.Room, m.peers(), consts.Media, changed)
+	Global.cluster.sync(m.Room, m.Device)
+}
+
+// applyMedia descp: return nil if nothing changed
+func (m *Member) applyMedia(diff *MediaDiff) *MediaDiff {
+	m.deviceLock.Lock()
+	defer m.deviceLock.Unlock()
+
+	changed := &MediaDiff{Id: m.Device.Id}
+	ok := false
+	apply := func(field *bool, value *bool) *bool {
+		if value == nil || *field == *value {
+			return nil
+		}
+		*field = *value
+		ok = true
+		return value
+	}
+
+	changed.AudioMuted = apply(&m.Device.AudioMuted, diff.AudioMuted)
+	changed.VideoMuted = apply(&m.Device.VideoMuted, diff.VideoMuted)
+	changed.ScreenSharing = apply(&m.Device.ScreenSharing, diff.ScreenSharing)
+
+	if !ok {
+		return nil
+	}
+	return changed
+}
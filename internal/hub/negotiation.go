@@ -0,0 +1,169 @@
+package hub
+
+import (
+	"sync"
+	"time"
+	"volo_meeting/consts"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+// Peer descp: a Device seen by the receiver, Polite is the role of the receiver in perfect negotiation with it.
+// on glare the impolite side rolls back its offer and the polite side ignores the incoming one,
+// so the clients act on the consts.Negotiation hint instead of deciding by Polite alone
+type Peer struct {
+	*Device
+	Polite bool `json:"polite"`
+}
+
+// Hint descp: sent as consts.Negotiation on glare, Id is the peer, Action is one of RollbackAction and IgnoreAction
+type Hint struct {
+	Id     DeviceId `json:"id"`
+	Action string   `json:"action"`
+}
+
+const (
+	RollbackAction = "rollback" // descp roll back the local offer and answer the one from the peer
+	IgnoreAction   = "ignore"   // descp ignore the offer from the peer, it has been told to roll back
+)
+
+// sdp types of RTCSessionDescription
+const (
+	offerType    = "offer"
+	answerType   = "answer"
+	rollbackType = "rollback"
+)
+
+type sessionDescription struct {
+	Type string `json:"type"`
+	Sdp  string `json:"sdp"`
+}
+
+// pair descp: two peers ordered by id
+type pair struct {
+	a, b DeviceId
+}
+
+func newPair(x, y DeviceId) pair {
+	if x > y {
+		x, y = y, x
+	}
+	return pair{x, y}
+}
+
+// offer descp: an offer waiting for the answer, it expires after consts.OfferTimeout
+// in case the answer is lost or the offer is rejected
+type offer struct {
+	from DeviceId
+	at   time.Time
+}
+
+// negotiations descp: the offer of each pair, in fan-out cluster mode a node only sees the offers of its own members
+type negotiations struct {
+	lock    sync.Mutex
+	offerer map[pair]*offer
+	now     func() time.Time
+}
+
+func newNegotiations() *negotiations {
+	return &negotiations{offerer: make(map[pair]*offer), now: time.Now}
+}
+
+// pending descp: the device whose offer of the pair is waiting for the answer, the expired one is dropped
+func (n *negotiations) pending(key pair) DeviceId {
+	o, ok := n.offerer[key]
+	if !ok {
+		return ""
+	}
+	if n.now().Sub(o.at) > consts.OfferTimeout {
+		delete(n.offerer, key)
+		return ""
+	}
+	return o.from
+}
+
+// isPolite descp: the device with the smaller id is the polite one of a pair
+func isPolite(self, peer DeviceId) bool {
+	return self < peer
+}
+
+func toPeers(self DeviceId, devices []*Device) []*Peer {
+	peers := make([]*Peer, 0, len(devices))
+	for _, device := range devices {
+		peers = append(peers, &Peer{Device: device, Polite: isPolite(self, device.Id)})
+	}
+	return peers
+}
+
+// negotiate descp: track the description from one to another, return false if it should be dropped.
+// on glare, the offer of the impolite side loses: it gets RollbackAction and the polite side gets IgnoreAction
+func (n *negotiations) negotiate(from, to DeviceId, sdpType string) (forward bool, hints map[DeviceId]*Hint) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	key := newPair(from, to)
+	switch sdpType {
+	case offerType:
+		if n.pending(key) != to {
+			n.offerer[key] = &offer{from, n.now()}
+			return true, nil
+		}
+		if !isPolite(from, to) {
+			return false, map[DeviceId]*Hint{from: {Id: to, Action: RollbackAction}}
+		}
+		n.offerer[key] = &offer{from, n.now()}
+		return true, map[DeviceId]*Hint{
+			to:   {Id: from, Action: RollbackAction},
+			from: {Id: to, Action: IgnoreAction},
+		}
+	case answerType:
+		delete(n.offerer, key)
+	case rollbackType:
+		if n.pending(key) == from {
+			delete(n.offerer, key)
+		}
+	}
+	return true, nil
+}
+
+// forget descp: drop the pairs of the device, once it leaves or its peers change
+func (n *negotiations) forget(deviceId DeviceId) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for key := range n.offerer {
+		if key.a == deviceId || key.b == deviceId {
+			delete(n.offerer, key)
+		}
+	}
+}
+
+// negotiate descp: filter the descriptions sent by m, and send the hints of glare
+func (m *Member) negotiate(data []Data) []Data {
+	forwarded := data[:0]
+	for _, d := range data {
+		desc := &sessionDescription{}
+		if d.Content == nil || jsoniter.Unmarshal(*d.Content, desc) != nil {
+			forwarded = append(forwarded, d)
+			continue
+		}
+		// descp the negotiation state stays on each node, so glare with a remote peer is left to the peers
+		if peer, ok := m.Room.Members.Get(d.Id); ok && peer.isRemote() {
+			forwarded = append(forwarded, d)
+			continue
+		}
+
+		forward, hints := m.Room.negotiations.negotiate(m.Device.Id, d.Id, desc.Type)
+		if forward {
+			forwarded = append(forwarded, d)
+		}
+		for deviceId, hint := range hints {
+			zap.L().Debug("negotiation glare", zap.String("deviceId", deviceId), zap.Any("hint", hint))
+			if member, ok := m.Room.Members.Get(deviceId); ok {
+				sendTo(member, &Message[*Hint]{member.NextId(), consts.Negotiation, hint})
+			}
+		}
+	}
+	return forwarded
+}
@@ -0,0 +1,96 @@
+package hub
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"volo_meeting/consts"
+)
+
+func TestNegotiations_Negotiate(t *testing.T) {
+	type step struct {
+		from, to    DeviceId
+		sdpType     string
+		wantForward bool
+		wantHints   map[DeviceId]*Hint
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{name: "offer and answer", steps: []step{
+			{"a", "b", offerType, true, nil},
+			{"b", "a", answerType, true, nil},
+			{"b", "a", offerType, true, nil},
+		}},
+		{name: "glare, impolite offers later", steps: []step{
+			{"a", "b", offerType, true, nil},
+			{"b", "a", offerType, false, map[DeviceId]*Hint{"b": {Id: "a", Action: RollbackAction}}},
+			{"b", "a", answerType, true, nil},
+		}},
+		{name: "glare, polite offers later", steps: []step{
+			{"b", "a", offerType, true, nil},
+			{"a", "b", offerType, true, map[DeviceId]*Hint{
+				"b": {Id: "a", Action: RollbackAction},
+				"a": {Id: "b", Action: IgnoreAction},
+			}},
+			{"b", "a", answerType, true, nil},
+			{"b", "a", offerType, true, nil},
+		}},
+		{name: "rollback", steps: []step{
+			{"a", "b", offerType, true, nil},
+			{"a", "b", rollbackType, true, nil},
+			{"b", "a", offerType, true, nil},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newNegotiations()
+			for i, s := range tt.steps {
+				forward, hints := n.negotiate(s.from, s.to, s.sdpType)
+				if forward != s.wantForward || !reflect.DeepEqual(hints, s.wantHints) {
+					t.Fatalf("step %d negotiate() = %v %v, want %v %v", i, forward, hints, s.wantForward, s.wantHints)
+				}
+			}
+		})
+	}
+}
+
+func TestNegotiations_Forget(t *testing.T) {
+	n := newNegotiations()
+	n.negotiate("a", "b", offerType)
+	n.negotiate("c", "b", offerType)
+	n.forget("b")
+
+	if forward, hints := n.negotiate("b", "a", offerType); !forward || hints != nil {
+		t.Errorf("negotiate() after forget = %v %v", forward, hints)
+	}
+	if len(n.offerer) != 1 {
+		t.Errorf("offerer = %v, want only b-a", n.offerer)
+	}
+}
+
+func TestNegotiations_Expire(t *testing.T) {
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		wantHints bool
+	}{
+		{name: "pending offer", elapsed: consts.OfferTimeout, wantHints: true},
+		{name: "stale offer", elapsed: consts.OfferTimeout + time.Second, wantHints: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			n := newNegotiations()
+			n.now = func() time.Time { return now }
+			n.negotiate("b", "a", offerType)
+
+			now = now.Add(tt.elapsed)
+			forward, hints := n.negotiate("a", "b", offerType)
+			if !forward || (hints != nil) != tt.wantHints {
+				t.Errorf("negotiate() = %v %v, want hints %v", forward, hints, tt.wantHints)
+			}
+		})
+	}
+}
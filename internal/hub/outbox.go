@@ -0,0 +1,222 @@
+package hub
+
+import (
+	"sync"
+	"time"
+	"volo_meeting/consts"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+// AckMode descp: reply of consts.AckMode, tell the client how the server retransmits
+type AckMode struct {
+	Timeout int64 `json:"timeout"` // descp milliseconds
+	Size    int   `json:"size"`
+}
+
+// Ack descp: ids of the acknowledged messages, sent by both sides
+type Ack struct {
+	Ids []int32 `json:"ids"`
+}
+
+type pending struct {
+	id      int32
+	message any
+	sentAt  time.Time
+	retries int
+}
+
+// outbox descp: keeps the unacked messages of a member once the client takes part in the ack handshake,
+// it is shared by the resumed member, so the pending messages are resent on the new conn
+type outbox struct {
+	lock    sync.Mutex
+	enabled bool
+	pending []*pending
+	seen    map[int32]struct{} // descp ids of the received client messages, for detecting duplicates
+	order   []int32
+	timer   *time.Timer
+}
+
+func newOutbox() *outbox {
+	return &outbox{seen: make(map[int32]struct{})}
+}
+
+func (o *outbox) enable() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.enabled = true
+}
+
+func (o *outbox) isEnabled() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.enabled
+}
+
+// push descp: the oldest message is dropped when outbox is full
+func (o *outbox) push(id int32, message any) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if !o.enabled {
+		return false
+	}
+
+	if len(o.pending) >= consts.DefaultOutboxSize {
+		zap.L().Info("outbox is full, drop the oldest", zap.Int32("id", o.pending[0].id))
+		o.pending = o.pending[1:]
+	}
+	o.pending = append(o.pending, &pending{id: id, message: message, sentAt: time.Now()})
+
+	return o.timer == nil
+}
+
+func (o *outbox) ack(ids []int32) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	set := make(map[int32]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+
+	pending := o.pending[:0]
+	for _, p := range o.pending {
+		if _, ok := set[p.id]; !ok {
+			pending = append(pending, p)
+		}
+	}
+	o.pending = pending
+}
+
+// due descp: return the messages to resend, all of them if timeout is 0,
+// the ones retransmitted more than consts.MaxRetransmit times are dropped
+func (o *outbox) due(timeout time.Duration) []any {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	now := time.Now()
+	messages := make([]any, 0)
+	pending := o.pending[:0]
+	for _, p := range o.pending {
+		if timeout > 0 && now.Sub(p.sentAt) < timeout {
+			pending = append(pending, p)
+			continue
+		}
+		if p.retries >= consts.MaxRetransmit {
+			zap.L().Info("drop unacked message", zap.Int32("id", p.id))
+			continue
+		}
+
+		p.retries++
+		p.sentAt = now
+		messages = append(messages, p.message)
+		pending = append(pending, p)
+	}
+	o.pending = pending
+
+	return messages
+}
+
+// schedule descp: run fn after consts.AckTimeout while there are pending messages
+func (o *outbox) schedule(fn func()) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+	o.timer = time.AfterFunc(consts.AckTimeout, func() {
+		o.lock.Lock()
+		o.timer = nil
+		empty := len(o.pending) == 0
+		o.lock.Unlock()
+
+		if !empty {
+			fn()
+		}
+	})
+}
+
+func (o *outbox) stop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.timer != nil {
+		o.timer.Stop()
+		o.timer = nil
+	}
+}
+
+// received descp: return true if the client message id has been seen
+func (o *outbox) received(id int32) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if _, ok := o.seen[id]; ok {
+		return true
+	}
+
+	if len(o.order) >= consts.DefaultOutboxSize {
+		delete(o.seen, o.order[0])
+		o.order = o.order[1:]
+	}
+	o.seen[id] = struct{}{}
+	o.order = append(o.order, id)
+
+	return false
+}
+
+// isReply descp: replies reuse the id of client message, so they are never kept in outbox
+func isReply(event consts.Event) bool {
+	return event == consts.Error || event == consts.Unreachable || event == consts.Ack || event == consts.AckMode || event == consts.Pong
+}
+
+// retransmit descp: resend the timeout messages, all of them if timeout is 0,
+// it pauses while member is reconnecting and everything is resent on resume
+func (m *Member) retransmit(timeout time.Duration) {
+	if !m.isOnline() {
+		return
+	}
+
+	messages := m.outbox.due(timeout)
+	if len(messages) > 0 {
+		zap.L().Debug("retransmit", zap.String("deviceId", m.Device.Id), zap.Int("count", len(messages)))
+	}
+	for _, message := range messages {
+		m.Conn.Send(message)
+	}
+
+	m.outbox.schedule(func() { m.retransmit(consts.AckTimeout) })
+}
+
+// handleAck descp: consts.AckMode is the handshake, consts.Ack acknowledges the message ids
+func (m *Member) handleAck(message *Message[jsoniter.RawMessage]) {
+	switch message.Event {
+	case consts.AckMode:
+		m.outbox.enable()
+		sendTo(m, &Message[*AckMode]{message.Id, consts.AckMode, &AckMode{
+			Timeout: consts.AckTimeout.Milliseconds(),
+			Size:    consts.DefaultOutboxSize,
+		}})
+	case consts.Ack:
+		ack := &Ack{}
+		if err := unmarshal(message.Data, ack); err != nil {
+			m.Conn.Emit(consts.Err, err, message.Id)
+			return
+		}
+		m.outbox.ack(ack.Ids)
+	}
+}
+
+// isDuplicate descp: in ack mode, ack every client message and drop the duplicated ones
+func (m *Member) isDuplicate(message *Message[jsoniter.RawMessage]) bool {
+	if message.Event == consts.Ack || message.Event == consts.AckMode || message.Event == consts.Ping || !m.outbox.isEnabled() {
+		return false
+	}
+
+	sendTo(m, &Message[*Ack]{message.Id, consts.Ack, &Ack{Ids: []int32{message.Id}}})
+
+	return m.outbox.received(message.Id)
+}
@@ -0,0 +1,94 @@
+package hub
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+)
+
+func TestOutbox_Due(t *testing.T) {
+	full := make([]int32, 0, consts.DefaultOutboxSize)
+	for id := int32(2); id <= consts.DefaultOutboxSize+1; id++ {
+		full = append(full, id)
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		push    int32 // descp push the messages of id 1 to push
+		ack     []int32
+		timeout time.Duration
+		rounds  int // descp the times due is called before the checked one
+		want    []int32
+	}{
+		{name: "not in ack mode", enabled: false, push: 3, want: []int32{}},
+		{name: "acked ones are not resent", enabled: true, push: 3, ack: []int32{2}, want: []int32{1, 3}},
+		{name: "not timed out", enabled: true, push: 2, timeout: time.Hour, want: []int32{}},
+		{name: "under the retransmit limit", enabled: true, push: 1, rounds: consts.MaxRetransmit - 1, want: []int32{1}},
+		{name: "over the retransmit limit", enabled: true, push: 1, rounds: consts.MaxRetransmit, want: []int32{}},
+		{name: "full outbox drops the oldest", enabled: true, push: consts.DefaultOutboxSize + 1, want: full},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOutbox()
+			if tt.enabled {
+				o.enable()
+			}
+			for id := int32(1); id <= tt.push; id++ {
+				o.push(id, id)
+			}
+			o.ack(tt.ack)
+			for i := 0; i < tt.rounds; i++ {
+				o.due(0)
+			}
+
+			got := make([]int32, 0)
+			for _, message := range o.due(tt.timeout) {
+				got = append(got, message.(int32))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("due() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutbox_Received(t *testing.T) {
+	o := newOutbox()
+	tests := []struct {
+		name string
+		id   int32
+		want bool
+	}{
+		{name: "first", id: 1, want: false},
+		{name: "another", id: 2, want: false},
+		{name: "duplicated", id: 1, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := o.received(tt.id); got != tt.want {
+				t.Errorf("received() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMember_Retransmit(t *testing.T) {
+	r := newTestRoom(&model.Meeting{})
+	m, client := addMember(t, r, "a", consts.Participant)
+	m.outbox.enable()
+	t.Cleanup(m.outbox.stop)
+
+	sendTo(m, &Message[DeviceId]{m.NextId(), consts.Leave, "b"})
+	sendTo(m, &Message[DeviceId]{m.NextId(), consts.Leave, "c"})
+	m.outbox.ack([]int32{1})
+	readMessage[DeviceId](t, client, consts.Leave)
+	readMessage[DeviceId](t, client, consts.Leave)
+
+	m.retransmit(0)
+	if message := readMessage[DeviceId](t, client, consts.Leave); message.Id != 2 || message.Data != "c" {
+		t.Errorf("retransmit() = %+v, want the unacked message 2", message)
+	}
+}
@@ -0,0 +1,256 @@
+package hub
+
+import (
+	"context"
+	"time"
+	"volo_meeting/consts"
+	"volo_meeting/internal/cache"
+	"volo_meeting/lib/db/redis"
+	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/id"
+	"volo_meeting/lib/rendezvous"
+	"volo_meeting/lib/ws"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// registry descp: in affinity mode every room is owned by a single node, nil means not in affinity mode.
+// a free room goes to the node picked by rendezvous hashing of the alive nodes, and stays there
+// while its owner is alive, so the nodes joining later don't split the running rooms
+type registry struct {
+	self *Node
+}
+
+// Node descp: Addr is the advertised base url, such as https://node1.example.com
+type Node struct {
+	Id   string `json:"id"`
+	Addr string `json:"addr"`
+	Beat int64  `json:"beat"` // descp unix seconds of the last heartbeat
+}
+
+// Redirect descp: sent to the device joining a room owned by another node, Url is where to join instead
+type Redirect struct {
+	Node string `json:"node"`
+	Url  string `json:"url"`
+}
+
+const nodesKey = "cluster:nodes"
+
+func ownerKey(meetingId MeetingId) string {
+	return "cluster:owner:" + meetingId
+}
+
+// renewOwnerScript descp: KEYS is ownerKey, ARGV are the node id and ttl in ms.
+// the owner is only renewed while it is still the node, so a room re-homed meanwhile is not taken back
+var renewOwnerScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseOwnerScript descp: KEYS is ownerKey, ARGV is the node id, the owner is only deleted while it is still the node
+var releaseOwnerScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func newRegistry() *registry {
+	node := viper.GetString("cluster.node")
+	if node == "" {
+		node = id.Must()
+	}
+	addr := viper.GetString("cluster.advertise")
+	if addr == "" {
+		addr = "http://" + viper.GetString("server.addr")
+	}
+
+	return &registry{self: &Node{Id: node, Addr: addr}}
+}
+
+func (g *registry) start() {
+	zap.L().Info("affinity mode", zap.String("node", g.self.Id), zap.String("addr", g.self.Addr))
+
+	g.beat()
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			g.beat()
+		}
+	}()
+}
+
+// beat descp: register the node, keep owning its rooms and forget the dead nodes
+func (g *registry) beat() {
+	ctx := context.Background()
+
+	g.self.Beat = time.Now().Unix()
+	data, err := jsoniter.MarshalToString(g.self)
+	if err != nil {
+		zap.L().Error("marshal node error", zap.Error(err))
+		return
+	}
+	if err = cache.HSet(ctx, nodesKey, map[string]any{g.self.Id: data}); err != nil {
+		zap.L().Error("node heartbeat error", zap.Error(err))
+		return
+	}
+
+	for _, meetingId := range Global.roomIds() {
+		renewed, err := cache.Eval(ctx, renewOwnerScript, []string{ownerKey(meetingId)}, g.self.Id, presenceTTL().Milliseconds())
+		if err != nil {
+			zap.L().Error("refresh room owner error", zap.Error(err))
+			continue
+		}
+		if renewed == int64(0) {
+			zap.L().Info("room owned by another node", zap.String("meetingId", meetingId))
+		}
+	}
+
+	if _, err = g.nodes(ctx); err != nil {
+		zap.L().Error("load nodes error", zap.Error(err))
+	}
+}
+
+// nodes descp: return the alive nodes, the dead ones are removed from the registry
+func (g *registry) nodes(ctx context.Context) (map[string]*Node, error) {
+	all, err := cache.HGetAll(ctx, nodesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(-presenceTTL()).Unix()
+	nodes := make(map[string]*Node, len(all))
+	dead := make([]string, 0)
+	for key, v := range all {
+		node := &Node{}
+		if err = jsoniter.UnmarshalFromString(v, node); err != nil || node.Beat < deadline {
+			dead = append(dead, key)
+			continue
+		}
+		nodes[node.Id] = node
+	}
+
+	if len(dead) > 0 {
+		zap.L().Info("remove dead nodes", zap.Strings("nodes", dead))
+		if err = cache.HDel(ctx, nodesKey, dead...); err != nil {
+			zap.L().Error("remove dead nodes error", zap.Error(err))
+		}
+	}
+	return nodes, nil
+}
+
+// owner descp: return the node owning the room, the room is claimed by this node if it is picked.
+// a room whose owner has died is re-homed to the node picked among the alive ones
+func (g *registry) owner(meetingId MeetingId) (*Node, error) {
+	ctx := context.Background()
+
+	current, err := cache.GetString(ctx, ownerKey(meetingId))
+	if err != nil {
+		return nil, error2.New(consts.CacheError, err)
+	}
+	if current == g.self.Id {
+		return g.self, nil
+	}
+
+	nodes, err := g.nodes(ctx)
+	if err != nil {
+		return nil, error2.New(consts.CacheError, err)
+	}
+	nodes[g.self.Id] = g.self
+	if node, ok := nodes[current]; ok {
+		return node, nil
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for nodeId := range nodes {
+		ids = append(ids, nodeId)
+	}
+	picked := nodes[rendezvous.Pick(meetingId, ids)]
+	if picked != g.self {
+		return picked, nil
+	}
+
+	if current != "" {
+		zap.L().Info("re-home room", zap.String("meetingId", meetingId), zap.String("from", current))
+		if err = cache.Del(ctx, ownerKey(meetingId)); err != nil {
+			return nil, error2.New(consts.CacheError, err)
+		}
+	}
+	ok, err := cache.SetNX(ctx, ownerKey(meetingId), g.self.Id, presenceTTL())
+	if err != nil {
+		return nil, error2.New(consts.CacheError, err)
+	}
+	if ok {
+		return g.self, nil
+	}
+
+	// descp claimed by another node at the same time
+	current, err = cache.GetString(ctx, ownerKey(meetingId))
+	if err != nil {
+		return nil, error2.New(consts.CacheError, err)
+	}
+	if node, ok := nodes[current]; ok {
+		return node, nil
+	}
+	return g.self, nil
+}
+
+// release descp: the room has been removed from this node
+func (g *registry) release(meetingId MeetingId) {
+	if g == nil {
+		return
+	}
+	if _, err := cache.Eval(context.Background(), releaseOwnerScript, []string{ownerKey(meetingId)}, g.self.Id); err != nil {
+		zap.L().Error("release room owner error", zap.Error(err))
+	}
+}
+
+// stop descp: leave the registry, the rooms of this node are re-homed when their members come back
+func (g *registry) stop() {
+	if g == nil {
+		return
+	}
+	ctx := context.Background()
+	if err := cache.HDel(ctx, nodesKey, g.self.Id); err != nil {
+		zap.L().Error("remove node error", zap.Error(err))
+	}
+	for _, meetingId := range Global.roomIds() {
+		g.release(meetingId)
+	}
+}
+
+// Locate descp: return the redirect to the owner of the room, nil means the room belongs to this node
+func (h *hub) Locate(meetingId MeetingId) (*Redirect, error) {
+	if h.registry == nil {
+		return nil, nil
+	}
+
+	node, err := h.registry.owner(meetingId)
+	if err != nil {
+		zap.L().Error("locate room error", zap.Error(err))
+		return nil, err
+	}
+	if node == h.registry.self {
+		return nil, nil
+	}
+	return &Redirect{Node: node.Id, Url: node.Addr}, nil
+}
+
+// SendRedirect descp: the conn has no member entry, so send consts.Redirect and close it once it is written
+func SendRedirect(conn *ws.Conn, redirect *Redirect) {
+	conn.Send(&Message[*Redirect]{1, consts.Redirect, redirect})
+	conn.CloseAfterFlush()
+}
+
+func (h *hub) roomIds() []MeetingId {
+	ids := make([]MeetingId, 0, h.rooms.Len())
+	h.rooms.Range(func(key MeetingId, value *Room) {
+		ids = append(ids, key)
+	})
+	return ids
+}
@@ -0,0 +1,31 @@
+package hub
+
+import (
+	"testing"
+	"volo_meeting/consts"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSendRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		redirect *Redirect
+	}{
+		{name: "owner node", redirect: &Redirect{Node: "node2", Url: "https://node2.example.com/api/v1/meeting/room?id=a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := newConn(t)
+			SendRedirect(conn, tt.redirect)
+
+			message := readMessage[*Redirect](t, client, consts.Redirect)
+			if *message.Data != *tt.redirect {
+				t.Errorf("SendRedirect() got = %+v, want %+v", message.Data, tt.redirect)
+			}
+			if _, _, err := client.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("ReadMessage() error = %v, want normal closure after redirect", err)
+			}
+		})
+	}
+}
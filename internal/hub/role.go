@@ -0,0 +1,191 @@
+package hub
+
+import (
+	"fmt"
+	"volo_meeting/consts"
+	error2 "volo_meeting/lib/error"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+// Target descp: the device a moderation event acts on, Role is only used by consts.RoleChange
+type Target struct {
+	Id   DeviceId          `json:"id"`
+	Role consts.MemberRole `json:"role,omitempty"`
+}
+
+func (d *Device) isHost() bool {
+	return d.Role == consts.Host
+}
+
+func (d *Device) canModerate() bool {
+	return d.Role == consts.Host || d.Role == consts.CoHost
+}
+
+// assignRole descp: the meeting creator always takes the host, otherwise the first joiner does.
+// return the devices whose role has been changed except the joining one
+func (r *Room) assignRole(device *Device) []*Device {
+	r.roleLock.Lock()
+	defer r.roleLock.Unlock()
+
+	host := r.host()
+	if device.Creator {
+		device.Role = consts.Host
+		if host != nil && host.Device != device {
+			return []*Device{host.setRole(consts.CoHost)}
+		}
+		return nil
+	}
+
+	if host == nil {
+		device.Role = consts.Host
+		return nil
+	}
+
+	if device.Role == "" || device.isHost() {
+		device.Role = consts.Participant
+	}
+	return nil
+}
+
+// handOverHost descp: when the host leaves, a co-host or else the earliest joiner becomes host
+func (r *Room) handOverHost(leaving *Device) []*Device {
+	if !leaving.isHost() {
+		return nil
+	}
+
+	r.roleLock.Lock()
+	defer r.roleLock.Unlock()
+
+	if r.host() != nil {
+		return nil
+	}
+
+	var next *Member
+	r.Members.Range(func(key DeviceId, value *Member) {
+		switch {
+		case next == nil:
+			next = value
+		case value.Device.Role == consts.CoHost && next.Device.Role != consts.CoHost:
+			next = value
+		case value.Device.Role == next.Device.Role && value.Device.JoinTime < next.Device.JoinTime:
+			next = value
+		}
+	})
+	if next == nil {
+		return nil
+	}
+
+	return []*Device{next.setRole(consts.Host)}
+}
+
+// host descp: caller must hold roleLock, under which the roles can be read without Member.deviceLock
+func (r *Room) host() *Member {
+	var host *Member
+	r.Members.Range(func(key DeviceId, value *Member) {
+		if value.Device.isHost() {
+			host = value
+		}
+	})
+	return host
+}
+
+// setRole descp: caller must hold roleLock, return the copy of the changed device
+func (m *Member) setRole(role consts.MemberRole) *Device {
+	m.deviceLock.Lock()
+	defer m.deviceLock.Unlock()
+	m.Device.Role = role
+	device := *m.Device
+	return &device
+}
+
+// moderate descp: handle the events that only host or co-host can send
+func (m *Member) moderate(message *Message[jsoniter.RawMessage]) {
+	self := m.device()
+	allowed := self.canModerate()
+	if message.Event == consts.RoleChange || message.Event == consts.TransferHost || message.Event == consts.End {
+		allowed = self.isHost()
+	}
+	if !allowed {
+		m.Conn.Emit(consts.Err, error2.NoPermission, message.Id)
+		return
+	}
+
+	switch message.Event {
+	case consts.End:
+		m.Room.endMeeting(m.Device.Id)
+		return
+	case consts.Lock, consts.Unlock:
+		m.Room.setLocked(message.Event == consts.Lock)
+		Global.cluster.shareLock(m.Room, message.Event)
+		patch(m.Room, m.Room.Members, message.Event, m.Device.Id)
+		return
+	}
+
+	target := &Target{}
+	err := jsoniter.Unmarshal(message.Data, target)
+	if err != nil {
+		zap.L().Error("unmarshal error", zap.Error(err))
+		sendTo(m, &Message[error]{message.Id, consts.Error, error2.New(consts.MarshalError, err)})
+		return
+	}
+
+	member, ok := m.Room.Members.Get(target.Id)
+	if !ok || member == m {
+		m.Conn.Emit(consts.Err, error2.New(consts.ParamError, fmt.Errorf("invalid target: %v", target.Id)), message.Id)
+		return
+	}
+	// descp the floor is revoked from whoever holds it, host included
+	if message.Event != consts.FloorRevoke && member.device().isHost() {
+		m.Conn.Emit(consts.Err, error2.NoPermission, message.Id)
+		return
+	}
+
+	zap.L().Debug("moderate", zap.String("deviceId", m.Device.Id), zap.Any("event", message.Event), zap.Any("target", target))
+
+	switch message.Event {
+	case consts.Kick:
+		// descp quit closes the conn after the notice is written
+		sendTo(member, &Message[DeviceId]{member.NextId(), consts.Kick, m.Device.Id})
+		go member.quit()
+	case consts.Mute:
+		sendTo(member, &Message[DeviceId]{member.NextId(), consts.Mute, m.Device.Id})
+	case consts.FloorRevoke:
+		if !member.dropFloor() {
+			m.Conn.Emit(consts.Err, error2.New(consts.ParamError, fmt.Errorf("not holding the floor: %v", target.Id)), message.Id)
+		}
+	case consts.RoleChange:
+		if target.Role != consts.CoHost && target.Role != consts.Participant {
+			m.Conn.Emit(consts.Err, error2.New(consts.ParamError, fmt.Errorf("invalid role: %v", target.Role)), message.Id)
+			return
+		}
+		m.Room.roleLock.Lock()
+		device := member.setRole(target.Role)
+		m.Room.roleLock.Unlock()
+
+		patch(m.Room, m.Room.Members, consts.RoleChange, []*Device{device})
+		Global.cluster.sync(m.Room, device)
+		if device.canModerate() && m.Room.Lobby.Len() > 0 {
+			m.Room.notifyLobby()
+		}
+	case consts.TransferHost:
+		m.Room.roleLock.Lock()
+		changed := []*Device{member.setRole(consts.Host), m.setRole(consts.CoHost)}
+		m.Room.roleLock.Unlock()
+
+		patch(m.Room, m.Room.Members, consts.RoleChange, changed)
+		Global.cluster.sync(m.Room, changed...)
+		if m.Room.Lobby.Len() > 0 {
+			m.Room.notifyLobby()
+		}
+	}
+}
+
+// endMeeting descp: tell every member the meeting is over, then close all conns.
+// by is empty when the meeting is ended by the server
+func (r *Room) endMeeting(by DeviceId) {
+	broadcast(r.Members, consts.End, by)
+
+	Global.removeRoom(r, true)
+}
@@ -0,0 +1,56 @@
+package hub
+
+import (
+	"testing"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestMember_Kick(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     consts.MemberRole
+		wantKick bool
+	}{
+		{name: "host kicks", role: consts.Host, wantKick: true},
+		{name: "co-host kicks", role: consts.CoHost, wantKick: true},
+		{name: "participant can't kick", role: consts.Participant, wantKick: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(&model.Meeting{})
+			moderator, moderatorClient := addMember(t, r, "a", tt.role)
+			_, targetClient := addMember(t, r, "b", consts.Participant)
+			moderator.setupEmitter()
+
+			data, _ := jsoniter.Marshal(map[string]any{"id": "b"})
+			moderator.moderate(&Message[jsoniter.RawMessage]{7, consts.Kick, data})
+
+			if !tt.wantKick {
+				if message := readMessage[*jsoniter.RawMessage](t, moderatorClient, consts.Error); message.Id != 7 {
+					t.Errorf("moderate() error id = %v, want 7", message.Id)
+				}
+				return
+			}
+
+			if message := readMessage[DeviceId](t, targetClient, consts.Kick); message.Data != "a" {
+				t.Errorf("moderate() kick by = %v, want a", message.Data)
+			}
+			// descp the conn ends with a normal closure after the notice
+			for {
+				if _, _, err := targetClient.ReadMessage(); err != nil {
+					if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+						t.Errorf("ReadMessage() error = %v, want normal closure after kick", err)
+					}
+					break
+				}
+			}
+			if message := readMessage[DeviceId](t, moderatorClient, consts.Leave); message.Data != "b" {
+				t.Errorf("moderate() leave = %v, want b", message.Data)
+			}
+		})
+	}
+}
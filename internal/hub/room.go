@@ -2,7 +2,9 @@ package hub
 
 import (
 	"fmt"
+	"sync"
 	"sync/atomic"
+	"time"
 	"volo_meeting/consts"
 	"volo_meeting/internal/model"
 	error2 "volo_meeting/lib/error"
@@ -15,6 +17,8 @@ import (
 
 type DeviceId = string
 
+type Members = tsmap.TSMap[DeviceId, *Member]
+
 type Message[T any] struct {
 	Id    int32        `json:"id"`
 	Event consts.Event `json:"event"`
@@ -27,66 +31,176 @@ type Data struct {
 }
 
 type Device struct {
-	Id       DeviceId `json:"id"`
-	Nickname string   `json:"nickname"`
-	JoinTime int64    `json:"-"`
+	Id        DeviceId          `json:"id"`
+	Nickname  string            `json:"nickname"`
+	Role      consts.MemberRole `json:"role"`
+	JoinTime  int64             `json:"-"`
+	RelayOnly bool              `json:"-"` // descp only the relay candidates of it are forwarded
+	Creator   bool              `json:"-"` // descp proved to be Meeting.HostId by the host key
+	MediaState
 }
 
 type Room struct {
-	Members tsmap.TSMap[DeviceId, *Member]
+	Members Members // descp all members of meeting, including the ones in breakouts
+	Main    Members // descp members not in any breakout
+	Lobby   tsmap.TSMap[DeviceId, *waiter]
 	Meeting *model.Meeting
+
+	roleLock sync.Mutex // descp serializes the role changes of the members, which also hold Member.deviceLock to write
+	joinLock sync.Mutex
+	locked   atomic.Bool
+
+	floorLock    sync.Mutex
+	floor        []DeviceId // descp holders of screen-share floor
+	floorVersion int64      // descp the version of floor in redis when clustered
+
+	breakoutLock     sync.Mutex
+	breakouts        map[string]*Breakout
+	assignments      map[DeviceId]string // descp kept after member leaves, so reconnecting goes back
+	selfSelect       bool
+	breakoutTimer    *time.Timer
+	breakoutDeadline time.Time // descp zero means breakouts are not closing
+
+	negotiations *negotiations
+
+	patchLock sync.Mutex
+	version   atomic.Int64 // descp increased by every patch of room state
+
+	idleTimer     *time.Timer // descp guarded by joinLock
+	scheduleTimer *time.Timer // descp guarded by joinLock
+	ended         bool
 }
 
 func newRoom(meeting *model.Meeting) *Room {
 	return &Room{
-		Members: tsmap.New[DeviceId, *Member](),
-		Meeting: meeting,
+		Members:      tsmap.New[DeviceId, *Member](),
+		Main:         tsmap.New[DeviceId, *Member](),
+		Lobby:        tsmap.New[DeviceId, *waiter](),
+		Meeting:      meeting,
+		negotiations: newNegotiations(),
+		breakouts:    make(map[string]*Breakout),
+		assignments:  make(map[DeviceId]string),
+	}
+}
+
+// Join descp: a matched resume token restores the existing member in place,
+// and a device taking an id in use without it is rejected.
+// the attendance and chat history are handled after joinLock is released, so they don't hold up other joins
+func (r *Room) Join(device *Device, conn *ws.Conn, token string) {
+	if member := r.join(device, conn, token); member != nil {
+		member.attend()
+		member.sendChatHistory()
 	}
 }
 
-func (r *Room) Join(device *Device, conn *ws.Conn) {
+// join descp: return the new member, nil if the device is rejected or resumed
+func (r *Room) join(device *Device, conn *ws.Conn, token string) *Member {
+	r.joinLock.Lock()
+	defer r.joinLock.Unlock()
+
+	if r.ended {
+		reject(device, conn, consts.WrongMeeting, error2.EndedMeeting)
+		return nil
+	}
+
 	member, ok := r.Members.Get(device.Id)
+	if ok && !member.isRemote() && member.takeOver(token) {
+		r.wake()
+		r.resume(member, conn)
+		return nil
+	}
+	if ok && !(member.isRemote() && member.owns(token)) {
+		reject(device, conn, consts.TakenId, error2.TakenId)
+		return nil
+	}
+	if !ok {
+		if errId, err := r.checkJoin(device); err != nil {
+			reject(device, conn, errId, err)
+			return nil
+		}
+	}
 	if ok {
-		member.Conn.Close()
+		// descp the session moved from another node, so it keeps its role
+		device.Role = member.device().Role
 		r.Members.Delete(device.Id)
+		r.negotiations.forget(device.Id)
 	}
 
+	r.wake()
+	changed := r.assignRole(device)
+
 	member = newMember(device, conn, r)
 
 	r.Members.Set(device.Id, member)
+	r.enter(member)
+	Global.cluster.join(member)
 
 	member.setupEmitter()
 
 	conn.Emit(consts.Join)
+
+	if len(changed) > 0 {
+		patch(r, r.Members, consts.RoleChange, changed, device.Id)
+		Global.cluster.sync(r, changed...)
+	}
+
+	if member.device().canModerate() && r.Lobby.Len() > 0 {
+		r.notifyLobby()
+	}
+
+	return member
 }
 
-func (r *Room) getDevices(exceptions ...DeviceId) []*Device {
-	devices := make([]*Device, 0, r.Members.Len()-len(exceptions))
+func getDevices(members Members, exceptions ...DeviceId) []*Device {
+	devices := make([]*Device, 0, members.Len())
 	fn := func(key DeviceId, value *Member) {
-		devices = append(devices, value.Device)
+		devices = append(devices, value.device())
 	}
 
-	r.Members.Range(fn, defaultExcept(exceptions...))
+	members.Range(fn, defaultExcept(exceptions...))
 
 	return devices
 }
 
 type Member struct {
 	autoIncrId *atomic.Int32
+	outbox     *outbox
+	deviceLock sync.Mutex // descp guards the fields of Device, which is copied by device before being shared
+	breakout   *Breakout  // descp nil means in Room.Main
 	Device     *Device
 	Room       *Room
 	Conn       *ws.Conn
+
+	node    string // descp non-empty means a remote member connected to that node, whose Conn is nil
+	version int64  // descp the room version last sent to the member, guarded by Room.patchLock
+
+	sessionLock  sync.Mutex
+	token        string // descp the digest of it for a remote member
+	reconnecting bool
+	quitted      bool
+	graceTimer   *time.Timer
+	iceTimer     *time.Timer // descp refreshes the TURN credentials
 }
 
 func newMember(device *Device, conn *ws.Conn, room *Room) *Member {
 	return &Member{
 		autoIncrId: &atomic.Int32{},
+		outbox:     newOutbox(),
 		Device:     device,
 		Room:       room,
 		Conn:       conn,
+		token:      newToken(),
 	}
 }
 
+// device descp: a copy of Device, so that it can be read or sent while the member is changed
+func (m *Member) device() *Device {
+	m.deviceLock.Lock()
+	defer m.deviceLock.Unlock()
+	device := *m.Device
+	return &device
+}
+
 func (m *Member) NextId() int32 {
 	return m.autoIncrId.Add(1)
 }
@@ -107,13 +221,40 @@ func (m *Member) setupEmitter() {
 
 		zap.L().Debug("receive message", zap.String("deviceId", m.Device.Id), zap.Any("message", message))
 
+		if m.isDuplicate(message) {
+			zap.L().Debug("drop duplicated message", zap.String("deviceId", m.Device.Id), zap.Int32("id", message.Id))
+			return
+		}
+
 		switch message.Event {
 		case consts.Description, consts.Candidate:
 			m.forwarding(m.Device.Id, message)
 		case consts.Device:
 			m.updateInfo(m.Device.Id, message)
+		case consts.Chat:
+			m.chat(message)
+		case consts.Whisper:
+			m.whisper(message)
+		case consts.Media:
+			m.updateMedia(message)
+		case consts.FloorRequest:
+			m.requestFloor(message.Id)
+		case consts.FloorRelease:
+			m.dropFloor()
+		case consts.Ping:
+			sendTo(m, pong(message))
+		case consts.RoomState:
+			m.sendRoomState()
+		case consts.AckMode, consts.Ack:
+			m.handleAck(message)
 		case consts.Leave:
-			m.Conn.Emit(consts.Close)
+			m.quit()
+		case consts.Kick, consts.Mute, consts.RoleChange, consts.TransferHost, consts.End, consts.Lock, consts.Unlock, consts.FloorRevoke:
+			m.moderate(message)
+		case consts.Admit, consts.Deny:
+			m.admission(message)
+		case consts.BreakoutOpen, consts.BreakoutClose, consts.BreakoutBroadcast, consts.BreakoutAssign, consts.BreakoutJoin:
+			m.handleBreakout(message)
 		default:
 			m.Conn.Emit(consts.Err, error2.New(consts.ParamError, fmt.Errorf("unknown event type: %v", message.Event)), message.Id)
 		}
@@ -122,19 +263,51 @@ func (m *Member) setupEmitter() {
 	m.Conn.On(consts.Join, func() {
 		zap.L().Debug("receive join", zap.String("deviceId", m.Device.Id))
 
-		sendTo(m, &Message[[]*Device]{m.NextId(), consts.Member, m.Room.getDevices(m.Device.Id)})
+		sendTo(m, &Message[*Session]{m.NextId(), consts.Session, m.session()})
+		m.sendRoomState()
+
+		if state := m.Room.breakoutState(m); len(state.Rooms) > 0 {
+			sendTo(m, &Message[*BreakoutState]{m.NextId(), consts.Breakout, state})
+		}
+
+		peers := m.peers()
+		sendTo(m, &Message[[]*Peer]{m.NextId(), consts.Member, toPeers(m.Device.Id, getDevices(peers, m.Device.Id))})
 
-		broadcast(m.Room, consts.Member, []*Device{m.Device}, m.Device.Id)
+		patchEach(m.Room, peers, consts.Member, func(member *Member) []*Peer {
+			return toPeers(member.Device.Id, []*Device{m.device()})
+		}, m.Device.Id)
+
+		if holders := m.Room.getFloor(); len(holders) > 0 {
+			sendTo(m, &Message[[]DeviceId]{m.NextId(), consts.Floor, holders})
+		}
 	})
 
-	m.Conn.On(consts.Close, func() {
-		zap.L().Debug("receive close", zap.String("deviceId", m.Device.Id))
+	m.Conn.On(consts.Resume, func() {
+		zap.L().Debug("receive resume", zap.String("deviceId", m.Device.Id))
+
+		sendTo(m, &Message[*Session]{m.NextId(), consts.Session, m.session()})
+		m.sendRoomState()
+
+		if state := m.Room.breakoutState(m); len(state.Rooms) > 0 {
+			sendTo(m, &Message[*BreakoutState]{m.NextId(), consts.Breakout, state})
+		}
+
+		peers := m.peers()
+		sendTo(m, &Message[[]*Peer]{m.NextId(), consts.Member, toPeers(m.Device.Id, getDevices(peers, m.Device.Id))})
+
+		patch(m.Room, peers, consts.Resumed, m.Device.Id, m.Device.Id)
+
+		m.retransmit(0)
 
-		m.Room.Members.Delete(m.Device.Id)
+		if holders := m.Room.getFloor(); len(holders) > 0 {
+			sendTo(m, &Message[[]DeviceId]{m.NextId(), consts.Floor, holders})
+		}
+	})
 
-		broadcast(m.Room, consts.Leave, m.Device.Id, m.Device.Id)
+	m.Conn.On(consts.Close, func() {
+		zap.L().Debug("receive close", zap.String("deviceId", m.Device.Id))
 
-		m.Conn.Close()
+		m.disconnect()
 	})
 
 	m.Conn.On(consts.Err, func(err error, messageId int32) {
@@ -158,15 +331,18 @@ func (m *Member) updateInfo(deviceId DeviceId, message *Message[jsoniter.RawMess
 		return
 	}
 
-	zap.L().Debug("update info", zap.Any("newDevice", device), zap.Any("oldDevice", m.Device))
+	zap.L().Debug("update info", zap.Any("newDevice", device), zap.Any("oldDevice", m.device()))
+	m.deviceLock.Lock()
 	m.Device.Nickname = device.Nickname
+	m.deviceLock.Unlock()
 
-	broadcast(m.Room, consts.Device, m.Device, deviceId)
+	patch(m.Room, m.peers(), consts.Device, m.device(), deviceId)
+	Global.cluster.sync(m.Room, m.Device)
 }
 
 // forwarding descp: forward message to specific device by Data.Id
 func (m *Member) forwarding(deviceId DeviceId, message *Message[jsoniter.RawMessage]) {
-	data := make([]Data, 0, m.Room.Members.Len()-1)
+	data := make([]Data, 0)
 	err := jsoniter.Unmarshal(message.Data, &data)
 	if err != nil {
 		zap.L().Error("unmarshal error", zap.Error(err))
@@ -176,17 +352,43 @@ func (m *Member) forwarding(deviceId DeviceId, message *Message[jsoniter.RawMess
 
 	zap.L().Debug("forwarding", zap.String("deviceId", deviceId), zap.Any("event", message.Event), zap.Any("forwarding data", data))
 
-	deliver(m.Room, message.Event, data, deviceId)
+	if message.Event == consts.Description {
+		if data = m.negotiate(m.describe(message.Id, data)); len(data) == 0 {
+			return
+		}
+	}
+	if message.Event == consts.Candidate {
+		if data = m.filterCandidates(data); len(data) == 0 {
+			return
+		}
+	}
+
+	if unknown := deliver(m.peers(), message.Event, data, deviceId); len(unknown) > 0 {
+		sendTo(m, &Message[[]DeviceId]{message.Id, consts.Unreachable, unknown})
+	}
 }
 
-// sendTo descp sendTo force the conn send Message type
+// sendTo descp sendTo force the conn send Message type, and keep it in outbox until acked in ack mode,
+// the message to a remote member is relayed to its node
 func sendTo[T any](member *Member, message *Message[T]) {
 	zap.L().Debug("send message", zap.String("deviceId", member.Device.Id), zap.Any("event", message.Event), zap.Any("data", message.Data))
+	if member.isRemote() {
+		Global.cluster.relay(member, message.Event, message.Data)
+		return
+	}
+	enqueue(member, message.Id, message.Event, message)
+}
+
+// enqueue descp: message is kept in outbox by id unless it is a reply
+func enqueue(member *Member, id int32, event consts.Event, message any) {
+	if !isReply(event) && member.outbox.push(id, message) {
+		member.outbox.schedule(func() { member.retransmit(consts.AckTimeout) })
+	}
 	member.Conn.Send(message)
 }
 
-// broadcast descp: broadcast message to all devices in room, except exceptions
-func broadcast[T any](r *Room, event consts.Event, data T, exceptions ...DeviceId) {
+// broadcast descp: broadcast message to all devices in members, except exceptions
+func broadcast[T any](members Members, event consts.Event, data T, exceptions ...DeviceId) {
 	fn := func(deviceId DeviceId, member *Member) {
 		sendTo(member, &Message[T]{
 			Id:    member.NextId(),
@@ -195,12 +397,12 @@ func broadcast[T any](r *Room, event consts.Event, data T, exceptions ...DeviceI
 		})
 	}
 
-	r.Members.Range(fn, defaultExcept(exceptions...))
+	members.Range(fn, defaultExcept(exceptions...))
 }
 
-// deliver descp: deliver message to specific device by Data.Id, and change Data.Id to fromId
-// message
-func deliver(r *Room, event consts.Event, data []Data, fromId DeviceId) {
+// deliver descp: deliver message to specific device by Data.Id, and change Data.Id to fromId,
+// return the Data.Id which is not in members
+func deliver(members Members, event consts.Event, data []Data, fromId DeviceId) []DeviceId {
 	set := make(map[DeviceId]*Message[[]Data], len(data))
 	for i, d := range data {
 		set[d.Id] = &Message[[]Data]{
@@ -217,10 +419,17 @@ func deliver(r *Room, event consts.Event, data []Data, fromId DeviceId) {
 				msg.Data[i].Id = fromId
 			}
 			sendTo(member, msg)
+			delete(set, deviceId)
 		}
 	}
 
-	r.Members.Range(fn)
+	members.Range(fn)
+
+	unknown := make([]DeviceId, 0, len(set))
+	for deviceId := range set {
+		unknown = append(unknown, deviceId)
+	}
+	return unknown
 }
 
 func defaultExcept(exceptions ...DeviceId) func(key DeviceId, value *Member) bool {
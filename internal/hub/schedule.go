@@ -0,0 +1,66 @@
+package hub
+
+import (
+	"time"
+	"volo_meeting/consts"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Overtime descp: sent every consts.OvertimeWarnInterval once a scheduled meeting runs past its duration
+type Overtime struct {
+	Deadline int64 `json:"deadline"` // descp unix seconds the meeting will be ended at
+	Left     int64 `json:"left"`     // descp seconds
+}
+
+func overtime() time.Duration {
+	if overtime := viper.GetInt("meeting.overtime"); overtime > 0 {
+		return time.Duration(overtime) * time.Second
+	}
+	return consts.DefaultOvertime
+}
+
+// schedule descp: start warning at the scheduled end of the meeting, do nothing for an instant meeting
+func (r *Room) schedule() {
+	end, ok := r.Meeting.ScheduledEnd()
+	if !ok {
+		return
+	}
+	deadline := end.Add(overtime())
+
+	r.joinLock.Lock()
+	defer r.joinLock.Unlock()
+
+	if r.ended {
+		return
+	}
+	r.scheduleTimer = time.AfterFunc(time.Until(end), func() { r.warnOvertime(deadline) })
+}
+
+// warnOvertime descp: broadcast the time left before deadline, and end the meeting when it comes
+func (r *Room) warnOvertime(deadline time.Time) {
+	left := time.Until(deadline)
+	if left <= 0 {
+		zap.L().Debug("scheduled meeting overtime", zap.String("meetingId", r.Meeting.Id))
+		r.endMeeting("")
+		return
+	}
+
+	r.joinLock.Lock()
+	if r.ended {
+		r.joinLock.Unlock()
+		return
+	}
+	next := consts.OvertimeWarnInterval
+	if left < next {
+		next = left
+	}
+	r.scheduleTimer = time.AfterFunc(next, func() { r.warnOvertime(deadline) })
+	r.joinLock.Unlock()
+
+	patch(r, r.Members, consts.Overtime, &Overtime{
+		Deadline: deadline.Unix(),
+		Left:     int64(left.Round(time.Second) / time.Second),
+	})
+}
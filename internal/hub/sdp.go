@@ -0,0 +1,55 @@
+package hub
+
+import (
+	"volo_meeting/consts"
+	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/sdp"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+// describe descp: rewrite the descriptions sent by m by the sdp policy of the meeting and the candidate policies.
+// a malformed description is dropped, and the sender gets consts.Error with consts.SdpError
+func (m *Member) describe(messageId int32, data []Data) []Data {
+	policy := m.Room.Meeting.SdpPolicy()
+	chain := m.candidates()
+
+	described := data[:0]
+	for _, d := range data {
+		fields := make(map[string]jsoniter.RawMessage)
+		desc := &sessionDescription{}
+		if d.Content == nil || jsoniter.Unmarshal(*d.Content, &fields) != nil || jsoniter.Unmarshal(*d.Content, desc) != nil ||
+			desc.Type == rollbackType {
+			described = append(described, d)
+			continue
+		}
+
+		session, err := sdp.Parse(desc.Sdp)
+		if err != nil {
+			zap.L().Debug("drop malformed sdp", zap.String("deviceId", m.Device.Id), zap.String("to", d.Id), zap.Error(err))
+			sendTo(m, &Message[error]{messageId, consts.Error, error2.New(consts.SdpError, err)})
+			continue
+		}
+		if policy.IsZero() && len(chain) == 0 {
+			described = append(described, d)
+			continue
+		}
+
+		policy.Apply(session)
+		if len(chain) > 0 {
+			m.filterDescription(chain, session)
+		}
+		if fields["sdp"], err = jsoniter.Marshal(session.String()); err == nil {
+			var content jsoniter.RawMessage
+			if content, err = jsoniter.Marshal(fields); err == nil {
+				d.Content = &content
+			}
+		}
+		if err != nil {
+			zap.L().Error("marshal sdp error", zap.Error(err))
+		}
+		described = append(described, d)
+	}
+	return described
+}
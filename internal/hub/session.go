@@ -0,0 +1,271 @@
+package hub
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+	"volo_meeting/consts"
+	"volo_meeting/internal/cache"
+	"volo_meeting/lib/id"
+	"volo_meeting/lib/turn"
+	"volo_meeting/lib/ws"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Session descp: sent to member on join, reconnect with Token in Grace seconds to resume.
+// Ice is nil if no ICE server is configured, consts.IceServers brings fresh credentials before Ice expires
+type Session struct {
+	Token string        `json:"token"`
+	Grace int           `json:"grace"`
+	Ice   *turn.Servers `json:"ice,omitempty"`
+}
+
+func reconnectGrace() time.Duration {
+	if !viper.IsSet("meeting.reconnect_grace") {
+		return consts.DefaultReconnectGrace
+	}
+	return time.Duration(viper.GetInt("meeting.reconnect_grace")) * time.Second
+}
+
+func newToken() string {
+	return id.Must()
+}
+
+func tokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func matchDigest(digest, token string) bool {
+	return digest != "" && subtle.ConstantTimeCompare([]byte(digest), []byte(tokenDigest(token))) == 1
+}
+
+func (m *Member) digest() string {
+	if m.isRemote() {
+		return m.token
+	}
+	return tokenDigest(m.token)
+}
+
+// CheckSession descp: whether token is the session token issued to the device on joining the meeting,
+// and the device is still in the meeting, reconnecting included
+func (h *hub) CheckSession(meetingId MeetingId, deviceId DeviceId, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	room, ok := h.rooms.Get(meetingId)
+	if !ok {
+		return h.cluster.checkSession(meetingId, deviceId, token)
+	}
+	member, ok := room.Members.Get(deviceId)
+	return ok && member.owns(token)
+}
+
+func attendanceKey(meetingId MeetingId) string {
+	return "attendance:" + meetingId
+}
+
+// attend descp: keep the digest of the session token after m leaves, so it can still read the chat history.
+// a rejoined device replaces the digest of its last session
+func (m *Member) attend() {
+	ctx := context.Background()
+	key := attendanceKey(m.Room.Meeting.Id)
+	if err := cache.HSet(ctx, key, map[string]any{m.Device.Id: m.digest()}); err != nil {
+		zap.L().Error("save attendance error", zap.Error(err))
+		return
+	}
+	if err := cache.Expire(ctx, key, consts.AttendanceExpire); err != nil {
+		zap.L().Error("expire attendance error", zap.Error(err))
+	}
+}
+
+// CheckAttendance descp: whether token is the session token of the last time the device joined the meeting,
+// whether it is still in the meeting or not
+func (h *hub) CheckAttendance(meetingId MeetingId, deviceId DeviceId, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	digest, err := cache.HGetString(context.Background(), attendanceKey(meetingId), deviceId)
+	if err != nil {
+		zap.L().Error("get attendance error", zap.Error(err))
+		return false
+	}
+	return matchDigest(digest, token)
+}
+
+// owns descp: whether token is the session token of m, which has not quitted
+func (m *Member) owns(token string) bool {
+	if m.isRemote() {
+		return matchDigest(m.token, token)
+	}
+
+	m.sessionLock.Lock()
+	defer m.sessionLock.Unlock()
+	return !m.quitted && token != "" && subtle.ConstantTimeCompare([]byte(m.token), []byte(token)) == 1
+}
+
+func (m *Member) session() *Session {
+	ice := turn.Issue(m.Device.Id)
+	m.refreshIce(ice)
+	return &Session{Token: m.token, Grace: int(reconnectGrace() / time.Second), Ice: ice}
+}
+
+// stopTimers descp: caller must hold sessionLock
+func (m *Member) stopTimers() {
+	if m.graceTimer != nil {
+		m.graceTimer.Stop()
+	}
+	if m.iceTimer != nil {
+		m.iceTimer.Stop()
+	}
+}
+
+// refreshIce descp: send consts.IceServers before the credentials in ice expire, until m quits.
+// nothing is sent while m is reconnecting, it gets new credentials on resume
+func (m *Member) refreshIce(ice *turn.Servers) {
+	d, ok := ice.RefreshIn(time.Now())
+	if !ok {
+		return
+	}
+
+	m.sessionLock.Lock()
+	defer m.sessionLock.Unlock()
+	if m.quitted {
+		return
+	}
+	if m.iceTimer != nil {
+		m.iceTimer.Stop()
+	}
+	m.iceTimer = time.AfterFunc(d, func() {
+		if !m.isOnline() || !m.isCurrent() {
+			return
+		}
+		ice := turn.Issue(m.Device.Id)
+		zap.L().Debug("refresh ice servers", zap.String("deviceId", m.Device.Id), zap.Int64("expires", ice.Expires))
+		sendTo(m, &Message[*turn.Servers]{m.NextId(), consts.IceServers, ice})
+		m.refreshIce(ice)
+	})
+}
+
+// isCurrent descp: false means m has been replaced by a rejoined or resumed member
+func (m *Member) isCurrent() bool {
+	current, ok := m.Room.Members.Get(m.Device.Id)
+	return ok && current == m
+}
+
+func (m *Member) isOnline() bool {
+	m.sessionLock.Lock()
+	defer m.sessionLock.Unlock()
+	return !m.reconnecting && !m.quitted
+}
+
+// disconnect descp: the socket is gone, keep member reconnecting for the grace period before quit
+func (m *Member) disconnect() {
+	m.Conn.Close()
+	if !m.isCurrent() {
+		return
+	}
+
+	grace := reconnectGrace()
+	if grace <= 0 {
+		m.quit()
+		return
+	}
+
+	m.sessionLock.Lock()
+	if m.quitted || m.reconnecting {
+		m.sessionLock.Unlock()
+		return
+	}
+	m.reconnecting = true
+	m.graceTimer = time.AfterFunc(grace, m.quit)
+	m.sessionLock.Unlock()
+	m.outbox.stop()
+
+	zap.L().Debug("member reconnecting", zap.String("deviceId", m.Device.Id))
+
+	patch(m.Room, m.peers(), consts.Reconnecting, m.Device.Id, m.Device.Id)
+}
+
+// quit descp: remove member from room and tell others it has left,
+// the conn is closed after the messages sent before are written, such as consts.Kick and consts.End
+func (m *Member) quit() {
+	if m.isRemote() {
+		Global.cluster.quit(m)
+		return
+	}
+
+	m.sessionLock.Lock()
+	if m.quitted {
+		m.sessionLock.Unlock()
+		return
+	}
+	m.quitted = true
+	m.stopTimers()
+	m.sessionLock.Unlock()
+
+	m.Conn.CloseAfterFlush()
+	if !m.isCurrent() {
+		return
+	}
+	m.outbox.stop()
+
+	zap.L().Debug("member quit", zap.String("deviceId", m.Device.Id))
+
+	m.Room.Members.Delete(m.Device.Id)
+	m.Room.negotiations.forget(m.Device.Id)
+	Global.cluster.leave(m)
+
+	patch(m.Room, m.Room.leave(m), consts.Leave, m.Device.Id, m.Device.Id)
+
+	if m.Room.releaseFloor(m.Device.Id) {
+		patch(m.Room, m.Room.Members, consts.Floor, m.Room.getFloor())
+	}
+
+	if changed := m.Room.handOverHost(m.device()); len(changed) > 0 {
+		patch(m.Room, m.Room.Members, consts.RoleChange, changed)
+		Global.cluster.sync(m.Room, changed...)
+		if m.Room.Lobby.Len() > 0 {
+			m.Room.notifyLobby()
+		}
+	}
+
+	m.Room.idle()
+}
+
+// takeOver descp: stop the old member by a matched token, return false if it has quitted
+func (m *Member) takeOver(token string) bool {
+	m.sessionLock.Lock()
+	defer m.sessionLock.Unlock()
+
+	if m.quitted || token == "" || token != m.token {
+		return false
+	}
+	m.quitted = true
+	m.stopTimers()
+	return true
+}
+
+// resume descp: replace old member with the new conn in place, peers only get consts.Resumed
+func (r *Room) resume(old *Member, conn *ws.Conn) {
+	member := newMember(old.Device, conn, r)
+	member.autoIncrId = old.autoIncrId
+	member.outbox = old.outbox
+	member.token = old.token
+
+	r.Members.Set(member.Device.Id, member)
+	r.enter(member)
+	old.Conn.Close()
+
+	zap.L().Debug("member resumed", zap.String("deviceId", member.Device.Id))
+
+	member.setupEmitter()
+
+	conn.Emit(consts.Resume)
+}
@@ -0,0 +1,117 @@
+package hub
+
+import (
+	"testing"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/viper"
+)
+
+func TestHub_CheckSession(t *testing.T) {
+	h := newHub()
+	r := newTestRoom(&model.Meeting{})
+	h.rooms.Set(r.Meeting.Id, r)
+	member, _ := addMember(t, r, "a", consts.Participant)
+	remote := newRemoteMember(&presence{Node: "node2", Device: &Device{Id: "b"}, Digest: tokenDigest("remote")}, r)
+	r.Members.Set("b", remote)
+
+	tests := []struct {
+		name      string
+		meetingId MeetingId
+		deviceId  DeviceId
+		token     string
+		want      bool
+	}{
+		{name: "local member", meetingId: r.Meeting.Id, deviceId: "a", token: member.token, want: true},
+		{name: "remote member", meetingId: r.Meeting.Id, deviceId: "b", token: "remote", want: true},
+		{name: "token of another device", meetingId: r.Meeting.Id, deviceId: "b", token: member.token, want: false},
+		{name: "empty token", meetingId: r.Meeting.Id, deviceId: "a", token: "", want: false},
+		{name: "not a member", meetingId: r.Meeting.Id, deviceId: "c", token: member.token, want: false},
+		{name: "no room", meetingId: "other", deviceId: "a", token: member.token, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.CheckSession(tt.meetingId, tt.deviceId, tt.token); got != tt.want {
+				t.Errorf("CheckSession() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	member.sessionLock.Lock()
+	member.quitted = true
+	member.sessionLock.Unlock()
+	if h.CheckSession(r.Meeting.Id, "a", member.token) {
+		t.Error("CheckSession() = true, want false after quit")
+	}
+}
+
+func TestRoom_Resume(t *testing.T) {
+	tests := []struct {
+		name         string
+		grace        int
+		ownToken     bool // descp reconnect with the token of the old member
+		token        string
+		wantResumed  bool
+		wantRejected bool
+	}{
+		{name: "matched token in grace", grace: 30, ownToken: true, wantResumed: true},
+		{name: "wrong token", grace: 30, token: newToken(), wantRejected: true},
+		{name: "no token", grace: 30, wantRejected: true},
+		{name: "grace passed", grace: 0, ownToken: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("meeting.reconnect_grace", tt.grace)
+			t.Cleanup(viper.Reset)
+
+			r := newTestRoom(&model.Meeting{})
+			_, peerClient := addMember(t, r, "b", consts.Participant)
+			conn, _ := newConn(t)
+			old := r.join(&Device{Id: "a"}, conn, "")
+			old.setRole(consts.Host)
+
+			old.disconnect()
+			if tt.grace > 0 {
+				if message := readMessage[DeviceId](t, peerClient, consts.Reconnecting); message.Data != "a" {
+					t.Errorf("disconnect() reconnecting = %v, want a", message.Data)
+				}
+			} else if message := readMessage[DeviceId](t, peerClient, consts.Leave); message.Data != "a" {
+				t.Errorf("disconnect() leave = %v, want a", message.Data)
+			}
+
+			token := tt.token
+			if tt.ownToken {
+				token = old.token
+			}
+			conn, client := newConn(t)
+			joined := r.join(&Device{Id: "a"}, conn, token)
+
+			current, ok := r.Members.Get("a")
+			if !ok {
+				t.Fatal("join() want a in the room")
+			}
+			if tt.wantRejected {
+				if message := readMessage[*jsoniter.RawMessage](t, client, consts.Error); message.Id != consts.TakenId {
+					t.Errorf("join() error id = %v, want %v", message.Id, consts.TakenId)
+				}
+				if joined != nil || current != old || current.device().Role != consts.Host {
+					t.Error("join() want the old member kept with its role")
+				}
+				return
+			}
+
+			if resumed := joined == nil; resumed != tt.wantResumed || current == old {
+				t.Fatalf("join() resumed = %v, want %v", resumed, tt.wantResumed)
+			}
+			session := readMessage[*Session](t, client, consts.Session)
+			if (session.Data.Token == old.token) != tt.wantResumed {
+				t.Errorf("join() session token = %v, old token %v", session.Data.Token, old.token)
+			}
+			if tt.wantResumed && current.autoIncrId != old.autoIncrId {
+				t.Error("join() want the message ids continued on resume")
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package hub
+
+import (
+	"time"
+	"volo_meeting/consts"
+
+	"go.uber.org/zap"
+)
+
+// RoomState descp: everything a member needs to render the room, sent as consts.RoomState on join, on resume
+// and whenever the client asks for it. the following patches go on from Version
+type RoomState struct {
+	Version  int64          `json:"version"`
+	Meeting  *MeetingInfo   `json:"meeting"`
+	Host     DeviceId       `json:"host"`
+	Locked   bool           `json:"locked"`
+	Members  []*MemberState `json:"members"` // descp the peers in the same breakout or main room, including itself
+	Floor    []DeviceId     `json:"floor"`
+	Breakout *BreakoutState `json:"breakout"`
+	Timers   []*Timer       `json:"timers"`
+}
+
+type MeetingInfo struct {
+	Id              string     `json:"id"`
+	FriendlyId      string     `json:"friendly_id"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	Lobby           bool       `json:"lobby"`
+	MaxParticipants int        `json:"max_participants"`
+	ScheduledStart  *time.Time `json:"scheduled_start"`
+	Duration        int        `json:"duration"`
+	StartTime       *time.Time `json:"start_time"`
+	SeriesId        *string    `json:"series_id,omitempty"`
+	AudioOnly       bool       `json:"audio_only"`
+}
+
+// MemberState descp: Polite is the role of the receiver in perfect negotiation with it
+type MemberState struct {
+	*Device
+	Reconnecting bool `json:"reconnecting"`
+	Polite       bool `json:"polite"`
+}
+
+// Timer descp: Deadline is in unix seconds
+type Timer struct {
+	Name     string `json:"name"`
+	Deadline int64  `json:"deadline"`
+}
+
+const (
+	ScheduledEndTimer  = "scheduled_end"
+	OvertimeTimer      = "overtime"
+	BreakoutCloseTimer = "breakout_close"
+)
+
+// Patch descp: a Message changing the room state, Base is the version last sent to the receiver
+type Patch[T any] struct {
+	Message[T]
+	Version int64 `json:"version"`
+	Base    int64 `json:"base"`
+}
+
+// patchEvents descp: the events changing the room state, they are sent as patches
+var patchEvents = map[consts.Event]bool{
+	consts.Member:          true,
+	consts.Leave:           true,
+	consts.Device:          true,
+	consts.Media:           true,
+	consts.RoleChange:      true,
+	consts.Lock:            true,
+	consts.Unlock:          true,
+	consts.Floor:           true,
+	consts.Reconnecting:    true,
+	consts.Resumed:         true,
+	consts.BreakoutClosing: true,
+	consts.Overtime:        true,
+}
+
+// patch descp: broadcast a change of room state with a new room version. every patch also carries the version
+// last sent to its receiver as Patch.Base, a client whose version differs from Base has missed something
+func patch[T any](r *Room, members Members, event consts.Event, data T, exceptions ...DeviceId) {
+	patchEach(r, members, event, func(*Member) T { return data }, exceptions...)
+}
+
+// patchEach descp: patch with the data built for each receiver
+func patchEach[T any](r *Room, members Members, event consts.Event, build func(member *Member) T, exceptions ...DeviceId) {
+	r.patchLock.Lock()
+	defer r.patchLock.Unlock()
+
+	version := r.version.Add(1)
+	members.Range(func(deviceId DeviceId, member *Member) {
+		sendPatch(member, version, event, build(member))
+	}, defaultExcept(exceptions...))
+}
+
+// sendPatch descp: caller must hold patchLock, the patch to a remote member is versioned again by its node
+func sendPatch[T any](member *Member, version int64, event consts.Event, data T) {
+	if member.isRemote() {
+		sendTo(member, &Message[T]{Event: event, Data: data})
+		return
+	}
+
+	base := member.version
+	member.version = version
+	message := &Patch[T]{Message[T]{member.NextId(), event, data}, version, base}
+	zap.L().Debug("send patch", zap.String("deviceId", member.Device.Id), zap.Any("event", event), zap.Int64("version", version))
+	enqueue(member, message.Id, event, message)
+}
+
+// sendRoomState descp: send the snapshot of current version, the patches after it are based on it
+func (m *Member) sendRoomState() {
+	r := m.Room
+	r.patchLock.Lock()
+	defer r.patchLock.Unlock()
+
+	state := r.state(m)
+	m.version = state.Version
+	sendTo(m, &Message[*RoomState]{m.NextId(), consts.RoomState, state})
+}
+
+// state descp: caller must hold patchLock, so that no patch is made meanwhile
+func (r *Room) state(m *Member) *RoomState {
+	meeting := r.Meeting
+	state := &RoomState{
+		Version: r.version.Load(),
+		Meeting: &MeetingInfo{
+			Id:              meeting.Id,
+			FriendlyId:      meeting.FriendlyId,
+			Title:           meeting.Title,
+			Description:     meeting.Description,
+			Lobby:           meeting.Lobby,
+			MaxParticipants: meeting.MaxParticipants,
+			ScheduledStart:  meeting.ScheduledStart,
+			Duration:        meeting.Duration,
+			StartTime:       meeting.StartTime,
+			SeriesId:        meeting.SeriesId,
+			AudioOnly:       meeting.AudioOnly,
+		},
+		Locked:   r.isLocked(),
+		Floor:    r.getFloor(),
+		Breakout: r.breakoutState(m),
+		Timers:   r.timers(),
+	}
+
+	r.roleLock.Lock()
+	if host := r.host(); host != nil {
+		state.Host = host.Device.Id
+	}
+	r.roleLock.Unlock()
+
+	peers := m.peers()
+	state.Members = make([]*MemberState, 0, peers.Len())
+	peers.Range(func(deviceId DeviceId, member *Member) {
+		state.Members = append(state.Members, &MemberState{
+			Device:       member.device(),
+			Reconnecting: !member.isOnline(),
+			Polite:       deviceId != m.Device.Id && isPolite(m.Device.Id, deviceId),
+		})
+	})
+
+	return state
+}
+
+func (r *Room) timers() []*Timer {
+	timers := make([]*Timer, 0)
+	if end, ok := r.Meeting.ScheduledEnd(); ok {
+		timers = append(timers,
+			&Timer{Name: ScheduledEndTimer, Deadline: end.Unix()},
+			&Timer{Name: OvertimeTimer, Deadline: end.Add(overtime()).Unix()},
+		)
+	}
+
+	r.breakoutLock.Lock()
+	if !r.breakoutDeadline.IsZero() {
+		timers = append(timers, &Timer{Name: BreakoutCloseTimer, Deadline: r.breakoutDeadline.Unix()})
+	}
+	r.breakoutLock.Unlock()
+
+	return timers
+}
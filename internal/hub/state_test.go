@@ -0,0 +1,86 @@
+package hub
+
+import (
+	"testing"
+	"time"
+	"volo_meeting/consts"
+	"volo_meeting/internal/model"
+
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// readPatch descp: read the next patch of the event from client, skipping the others
+func readPatch(t *testing.T, client *websocket.Conn, event consts.Event) *Patch[DeviceId] {
+	t.Helper()
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %v: %v", event, err)
+		}
+		patch := &Patch[jsoniter.RawMessage]{}
+		if err = jsoniter.Unmarshal(data, patch); err != nil {
+			t.Fatal(err)
+		}
+		if patch.Event == event {
+			data := ""
+			_ = jsoniter.Unmarshal(patch.Data, &data)
+			return &Patch[DeviceId]{Message[DeviceId]{patch.Id, patch.Event, data}, patch.Version, patch.Base}
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	type step struct {
+		except   []DeviceId
+		snapshot DeviceId              // descp send the room state to it instead of a patch
+		want     map[DeviceId][2]int64 // descp version and base of the patch each receiver gets
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{name: "consecutive versions", steps: []step{
+			{want: map[DeviceId][2]int64{"a": {1, 0}, "b": {1, 0}}},
+			{want: map[DeviceId][2]int64{"a": {2, 1}, "b": {2, 1}}},
+		}},
+		{name: "base is the version last sent to the receiver", steps: []step{
+			{except: []DeviceId{"b"}, want: map[DeviceId][2]int64{"a": {1, 0}}},
+			{want: map[DeviceId][2]int64{"a": {2, 1}, "b": {2, 0}}},
+		}},
+		{name: "patches go on from the snapshot", steps: []step{
+			{except: []DeviceId{"b"}, want: map[DeviceId][2]int64{"a": {1, 0}}},
+			{snapshot: "b", want: map[DeviceId][2]int64{"b": {1, 0}}},
+			{want: map[DeviceId][2]int64{"a": {2, 1}, "b": {2, 1}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(&model.Meeting{})
+			members := make(map[DeviceId]*Member)
+			clients := make(map[DeviceId]*websocket.Conn)
+			for _, deviceId := range []DeviceId{"a", "b"} {
+				members[deviceId], clients[deviceId] = addMember(t, r, deviceId, consts.Participant)
+			}
+
+			for i, s := range tt.steps {
+				if s.snapshot != "" {
+					members[s.snapshot].sendRoomState()
+					state := readMessage[*RoomState](t, clients[s.snapshot], consts.RoomState)
+					if state.Data.Version != s.want[s.snapshot][0] || len(state.Data.Members) != 2 {
+						t.Fatalf("step %d sendRoomState() version = %v members = %v, want %v", i, state.Data.Version, len(state.Data.Members), s.want[s.snapshot][0])
+					}
+					continue
+				}
+
+				patch(r, r.Members, consts.Leave, DeviceId("c"), s.except...)
+				for deviceId, want := range s.want {
+					if got := readPatch(t, clients[deviceId], consts.Leave); got.Version != want[0] || got.Base != want[1] {
+						t.Fatalf("step %d patch() to %v = version %v base %v, want %v", i, deviceId, got.Version, got.Base, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+type Chat struct {
+	Id        int64     `json:"id" gorm:"primary_key;autoIncrement"`
+	MeetingId string    `json:"meeting_id" gorm:"type:varchar(21);index;not null"`
+	Breakout  string    `json:"breakout,omitempty" gorm:"type:varchar(64);not null;default:''"` // descp the breakout where the public chat is sent, empty means the main room
+	SenderId  string    `json:"sender_id" gorm:"type:varchar(20);not null"`
+	Nickname  string    `json:"nickname" gorm:"type:varchar(64)"`
+	Content   string    `json:"content" gorm:"type:text;not null"`
+	Private   bool      `json:"private" gorm:"not null;default:false"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:datetime;index"`
+
+	Recipients []ChatRecipient `json:"recipients,omitempty" gorm:"foreignKey:ChatId"`
+}
+
+// ChatRecipient descp: recipients of a private chat, only they and the sender can see it
+type ChatRecipient struct {
+	ChatId   int64  `json:"-" gorm:"primary_key;autoIncrement:false"`
+	DeviceId string `json:"id" gorm:"type:varchar(20);primary_key;index"`
+}
+
+func (c *Chat) Create(db *gorm.DB) error {
+	return db.Model(c).Create(c).Error
+}
+
+// FindChats descp: page backwards from the message before beforeId, 0 means from the latest,
+// public chats are the ones sent in breakout, empty for the main room, private chats of any breakout are only visible
+// to their sender and recipients, the result is sorted by id asc
+func FindChats(db *gorm.DB, meetingId, deviceId, breakout string, beforeId int64, limit int) ([]*Chat, error) {
+	chats := make([]*Chat, 0, limit)
+	query := db.Model(&Chat{}).Preload("Recipients").Where("meeting_id = ?", meetingId)
+	if deviceId == "" {
+		query = query.Where("private = ? AND breakout = ?", false, breakout)
+	} else {
+		query = query.Where(
+			"(private = ? AND breakout = ?) OR (private = ? AND (sender_id = ? OR id IN (?)))",
+			false, breakout, true, deviceId, db.Model(&ChatRecipient{}).Select("chat_id").Where("device_id = ?", deviceId),
+		)
+	}
+	if beforeId > 0 {
+		query = query.Where("id < ?", beforeId)
+	}
+
+	err := query.Order("id desc").Limit(limit).Find(&chats).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(chats)-1; i < j; i, j = i+1, j-1 {
+		chats[i], chats[j] = chats[j], chats[i]
+	}
+	return chats, nil
+}
@@ -22,6 +22,10 @@ func migrate() {
 	err := instance.AutoMigrate(
 		&Meeting{},
 		&Device{},
+		&Chat{},
+		&ChatRecipient{},
+		&Series{},
+		&SeriesException{},
 	)
 	if err != nil {
 		panic(err)
@@ -1,15 +1,36 @@
 package model
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"strings"
 	"time"
+	"volo_meeting/lib/sdp"
 )
 
 type Meeting struct {
-	Id         string     `json:"id" gorm:"type:varchar(20);primary_key"`
-	FriendlyId string     `json:"friendly_id" gorm:"type:varchar(20);index;not null"`
-	StartTime  *time.Time `json:"start_time" gorm:"type:datetime;index"`
-	EndTime    *time.Time `json:"end_time" gorm:"type:datetime;index"`
+	Id              string     `json:"id" gorm:"type:varchar(20);primary_key"`
+	FriendlyId      string     `json:"friendly_id" gorm:"type:varchar(20);index;not null"`
+	HostId          string     `json:"host_id" gorm:"type:varchar(20)"`
+	Lobby           bool       `json:"lobby" gorm:"not null;default:false"`
+	MaxParticipants int        `json:"max_participants" gorm:"not null;default:0"` // descp 0 means no limit
+	Passcode        string     `json:"-" gorm:"type:varchar(60)"`                  // descp bcrypt hash with its salt
+	HostKey         string     `json:"-" gorm:"type:varchar(64)"`                  // descp sha256 digest of the key issued to the creator
+	Title           string     `json:"title" gorm:"type:varchar(128)"`
+	Description     string     `json:"description" gorm:"type:text"`
+	ScheduledStart  *time.Time `json:"scheduled_start" gorm:"type:datetime;index;uniqueIndex:idx_series_occurrence,priority:2"`  // descp nil means an instant meeting
+	Duration        int        `json:"duration" gorm:"not null;default:0"`                                                       // descp minutes
+	EarlyJoin       int        `json:"early_join" gorm:"not null;default:0"`                                                     // descp minutes allowed to join before ScheduledStart
+	SeriesId        *string    `json:"series_id,omitempty" gorm:"type:varchar(20);uniqueIndex:idx_series_occurrence,priority:1"` // descp nil means not an occurrence of Series
+	Codecs          string     `json:"codecs" gorm:"type:varchar(255)"`                                                          // descp allowed codecs separated by comma in the order of preference, empty means any
+	MaxBitrate      int        `json:"max_bitrate" gorm:"not null;default:0"`                                                    // descp kbps of video, 0 means no limit
+	AudioOnly       bool       `json:"audio_only" gorm:"not null;default:false"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"type:datetime;index"`
+	StartTime       *time.Time `json:"start_time" gorm:"type:datetime;index"`
+	EndTime         *time.Time `json:"end_time" gorm:"type:datetime;index"`
 
 	Devices []Device `json:"devices" gorm:"many2many:meeting_device;"`
 }
@@ -26,6 +47,83 @@ func (m *Meeting) AppendDevice(db *gorm.DB, device *Device) error {
 	return db.Model(m).Association("Devices").Append(&device)
 }
 
+// SetPasscode descp: only the salted hash of passcode is kept, empty passcode means no passcode
+func (m *Meeting) SetPasscode(passcode string) error {
+	hash, err := hashPasscode(passcode)
+	if err != nil {
+		return err
+	}
+	m.Passcode = hash
+	return nil
+}
+
+func hashPasscode(passcode string) (string, error) {
+	if passcode == "" {
+		return "", nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(passcode), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func (m *Meeting) HasPasscode() bool {
+	return m.Passcode != ""
+}
+
+func (m *Meeting) CheckPasscode(passcode string) bool {
+	if !m.HasPasscode() {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(m.Passcode), []byte(passcode)) == nil
+}
+
+// SetHostKey descp: only the digest of key is kept, empty key means the creator can't be proved
+func (m *Meeting) SetHostKey(key string) {
+	m.HostKey = digestHostKey(key)
+}
+
+func digestHostKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsCreator descp: whether the device is HostId and holds the key issued to it on creation
+func (m *Meeting) IsCreator(deviceId, key string) bool {
+	if m.HostId == "" || m.HostKey == "" || deviceId != m.HostId {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(m.HostKey), []byte(digestHostKey(key))) == 1
+}
+
+func (m *Meeting) IsScheduled() bool {
+	return m.ScheduledStart != nil && m.Duration > 0
+}
+
+// ScheduledEnd descp: ok is false for an instant meeting
+func (m *Meeting) ScheduledEnd() (end time.Time, ok bool) {
+	if !m.IsScheduled() {
+		return time.Time{}, false
+	}
+	return m.ScheduledStart.Add(time.Duration(m.Duration) * time.Minute), true
+}
+
+// Opened descp: a scheduled meeting can be joined EarlyJoin minutes before ScheduledStart
+func (m *Meeting) Opened(now time.Time) bool {
+	if !m.IsScheduled() {
+		return true
+	}
+	return !now.Before(m.ScheduledStart.Add(-time.Duration(m.EarlyJoin) * time.Minute))
+}
+
+// Missed descp: a scheduled meeting never started can't be joined after ScheduledEnd
+func (m *Meeting) Missed(now time.Time) bool {
+	end, ok := m.ScheduledEnd()
+	return ok && m.StartTime == nil && now.After(end)
+}
+
 func (m *Meeting) Update(db *gorm.DB, updates map[string]any) error {
 	return db.Model(m).Updates(updates).Error
 }
@@ -41,3 +139,22 @@ func (m *Meeting) EndNow(db *gorm.DB) error {
 		"end_time": time.Now(),
 	})
 }
+
+// ExpireUnstarted descp: end the meetings created before the time but never started,
+// a scheduled meeting is kept until its scheduled start is before the time too
+func ExpireUnstarted(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Model(&Meeting{}).
+		Where("start_time IS NULL AND end_time IS NULL AND created_at < ?", before).
+		Where("scheduled_start IS NULL OR scheduled_start < ?", before).
+		Update("end_time", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// SdpPolicy descp: the rewriting of the session descriptions exchanged in the meeting
+func (m *Meeting) SdpPolicy() *sdp.Policy {
+	policy := &sdp.Policy{MaxBitrate: m.MaxBitrate, AudioOnly: m.AudioOnly}
+	if m.Codecs != "" {
+		policy.Codecs = strings.Split(m.Codecs, ",")
+	}
+	return policy
+}
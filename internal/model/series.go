@@ -0,0 +1,141 @@
+package model
+
+import (
+	"errors"
+	"time"
+	"volo_meeting/lib/rrule"
+
+	"gorm.io/gorm"
+)
+
+var ErrSeriesOver = errors.New("series has no more occurrence")
+
+// Series descp: a recurring meeting, every occurrence is a Meeting linked by SeriesId,
+// which is created on demand when someone joins through the series' friendly id
+type Series struct {
+	Id              string    `json:"id" gorm:"type:varchar(20);primary_key"`
+	FriendlyId      string    `json:"friendly_id" gorm:"type:varchar(20);index;not null"`
+	HostId          string    `json:"host_id" gorm:"type:varchar(20)"`
+	Lobby           bool      `json:"lobby" gorm:"not null;default:false"`
+	MaxParticipants int       `json:"max_participants" gorm:"not null;default:0"`
+	Passcode        string    `json:"-" gorm:"type:varchar(60)"`
+	HostKey         string    `json:"-" gorm:"type:varchar(64)"`
+	Title           string    `json:"title" gorm:"type:varchar(128)"`
+	Description     string    `json:"description" gorm:"type:text"`
+	Rule            string    `json:"rule" gorm:"type:varchar(255);not null"`    // descp RRULE without DTSTART
+	Timezone        string    `json:"timezone" gorm:"type:varchar(64);not null"` // descp IANA name or fixed offset like +08:00
+	Start           time.Time `json:"start" gorm:"type:datetime;not null"`       // descp the first occurrence, as DTSTART
+	Duration        int       `json:"duration" gorm:"not null"`                  // descp minutes
+	EarlyJoin       int       `json:"early_join" gorm:"not null;default:0"`
+	Codecs          string    `json:"codecs" gorm:"type:varchar(255)"`
+	MaxBitrate      int       `json:"max_bitrate" gorm:"not null;default:0"`
+	AudioOnly       bool      `json:"audio_only" gorm:"not null;default:false"`
+	CreatedAt       time.Time `json:"created_at" gorm:"type:datetime;index"`
+
+	Exceptions []SeriesException `json:"exceptions" gorm:"foreignKey:SeriesId"`
+}
+
+// SeriesException descp: an occurrence of the series that is cancelled, as EXDATE
+type SeriesException struct {
+	SeriesId string    `json:"-" gorm:"type:varchar(20);primary_key"`
+	Start    time.Time `json:"start" gorm:"type:datetime;primary_key"`
+}
+
+func (s *Series) Create(db *gorm.DB) error {
+	return db.Model(s).Create(s).Error
+}
+
+func (s *Series) FindById(db *gorm.DB) error {
+	return db.Model(s).Preload("Exceptions").Where("id = ?", s.Id).First(s).Error
+}
+
+// SetPasscode descp: the hash is copied to every occurrence
+func (s *Series) SetPasscode(passcode string) error {
+	hash, err := hashPasscode(passcode)
+	if err != nil {
+		return err
+	}
+	s.Passcode = hash
+	return nil
+}
+
+// SetHostKey descp: the digest is copied to every occurrence
+func (s *Series) SetHostKey(key string) {
+	s.HostKey = digestHostKey(key)
+}
+
+// Location descp: the occurrences keep the wall clock time of Start in this location
+func (s *Series) Location() (*time.Location, error) {
+	if t, err := time.Parse("-07:00", s.Timezone); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(s.Timezone, offset), nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+func (s *Series) Set() (*rrule.Set, error) {
+	rule, err := rrule.Parse(s.Rule)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := s.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	exceptions := make([]time.Time, 0, len(s.Exceptions))
+	for _, e := range s.Exceptions {
+		exceptions = append(exceptions, e.Start)
+	}
+	return &rrule.Set{Rule: rule, Start: s.Start.In(loc), Exceptions: exceptions}, nil
+}
+
+// Next descp: the start of the first occurrence not ended before now, return ErrSeriesOver if there isn't
+func (s *Series) Next(now time.Time) (time.Time, error) {
+	set, err := s.Set()
+	if err != nil {
+		return time.Time{}, err
+	}
+	start, ok := set.After(now.Add(-time.Duration(s.Duration) * time.Minute))
+	if !ok {
+		return time.Time{}, ErrSeriesOver
+	}
+	return start, nil
+}
+
+// Occurrence descp: build the Meeting of the occurrence starting at start, not saved
+func (s *Series) Occurrence(start time.Time) *Meeting {
+	return &Meeting{
+		FriendlyId:      s.FriendlyId,
+		HostId:          s.HostId,
+		Lobby:           s.Lobby,
+		MaxParticipants: s.MaxParticipants,
+		Passcode:        s.Passcode,
+		HostKey:         s.HostKey,
+		Title:           s.Title,
+		Description:     s.Description,
+		ScheduledStart:  &start,
+		Duration:        s.Duration,
+		EarlyJoin:       s.EarlyJoin,
+		SeriesId:        &s.Id,
+		Codecs:          s.Codecs,
+		MaxBitrate:      s.MaxBitrate,
+		AudioOnly:       s.AudioOnly,
+	}
+}
+
+// FindRunning descp: the occurrence started but not ended yet, which may run over its duration
+func (s *Series) FindRunning(db *gorm.DB) (*Meeting, error) {
+	meeting := &Meeting{}
+	err := db.Model(meeting).
+		Where("series_id = ? AND start_time IS NOT NULL AND end_time IS NULL", s.Id).
+		Order("scheduled_start desc").
+		First(meeting).Error
+	return meeting, err
+}
+
+func (s *Series) FindOccurrence(db *gorm.DB, start time.Time) (*Meeting, error) {
+	meeting := &Meeting{}
+	err := db.Model(meeting).Where("series_id = ? AND scheduled_start = ?", s.Id, start).First(meeting).Error
+	return meeting, err
+}
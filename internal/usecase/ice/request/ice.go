@@ -0,0 +1,9 @@
+package request
+
+// IceQuery descp: Id is the device id, it goes into the TURN username.
+// Token is the session token issued to the device on joining the meeting
+type IceQuery struct {
+	MeetingId string `form:"meeting_id" binding:"required"`
+	Id        string `form:"id" binding:"required,max=64,excludesall=0x3A"`
+	Token     string `form:"token" binding:"required"`
+}
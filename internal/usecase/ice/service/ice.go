@@ -0,0 +1,36 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"volo_meeting/internal/hub"
+	"volo_meeting/internal/usecase/ice/request"
+	"volo_meeting/lib/callback"
+	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/turn"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetIceServers descp: only the device in the meeting gets the credentials, the owner node checks its session token
+func GetIceServers(ctx *gin.Context, query *request.IceQuery) {
+	redirect, err := hub.Global.Locate(query.MeetingId)
+	if err != nil {
+		callback.Error(ctx, err)
+		return
+	}
+	if redirect != nil {
+		ctx.Redirect(http.StatusTemporaryRedirect, strings.TrimSuffix(redirect.Url, "/")+ctx.Request.URL.RequestURI())
+		return
+	}
+	if !hub.Global.CheckSession(query.MeetingId, query.Id, query.Token) {
+		callback.Error(ctx, error2.InvalidSession)
+		return
+	}
+
+	servers := turn.Issue(query.Id)
+	if servers == nil {
+		servers = &turn.Servers{IceServers: make([]*turn.ICEServer, 0)}
+	}
+	callback.Success(ctx, servers)
+}
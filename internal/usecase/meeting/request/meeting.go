@@ -1,6 +1,70 @@
 package request
 
+import "time"
+
+// MeetingInfo descp: HostKey is only issued to the creator, who joins with it to be the host
 type MeetingInfo struct {
 	Id         string `json:"id"`
 	FriendlyId string `json:"friendly_id"`
+	HostKey    string `json:"host_key,omitempty"`
+}
+
+// MeetingOption descp: Codecs are the allowed codecs in the order of preference, MaxBitrate is the kbps of video
+type MeetingOption struct {
+	HostId          string   `form:"id" json:"id"`
+	Lobby           bool     `form:"lobby" json:"lobby"`
+	Passcode        string   `form:"passcode" json:"passcode" binding:"max=72"`
+	MaxParticipants int      `form:"max_participants" json:"max_participants" binding:"min=0"`
+	Codecs          []string `form:"codecs" json:"codecs" binding:"max=16,dive,required,max=32,excludesall=0x2C"`
+	MaxBitrate      int      `form:"max_bitrate" json:"max_bitrate" binding:"min=0"`
+	AudioOnly       bool     `form:"audio_only" json:"audio_only"`
+}
+
+// ScheduleOption descp: Duration and EarlyJoin are in minutes
+type ScheduleOption struct {
+	MeetingOption
+	Title          string    `json:"title" binding:"required,max=128"`
+	Description    string    `json:"description" binding:"max=2000"`
+	ScheduledStart time.Time `json:"scheduled_start" binding:"required"`
+	Duration       int       `json:"duration" binding:"required,min=1"`
+	EarlyJoin      int       `json:"early_join" binding:"min=0"`
+}
+
+// SeriesOption descp: ScheduledStart is the first occurrence, Rule is required for the custom frequency,
+// Timezone is an IANA name keeping the wall clock across DST, the offset of ScheduledStart by default
+type SeriesOption struct {
+	ScheduleOption
+	Frequency  string      `json:"frequency" binding:"required,oneof=daily weekly custom"`
+	Rule       string      `json:"rule" binding:"required_if=Frequency custom,max=255"`
+	Timezone   string      `json:"timezone" binding:"max=64"`
+	Exceptions []time.Time `json:"exceptions" binding:"max=366"` // descp start time of the cancelled occurrences
+}
+
+type SeriesInfo struct {
+	Id         string    `json:"id"`
+	FriendlyId string    `json:"friendly_id"`
+	HostKey    string    `json:"host_key,omitempty"`
+	Rule       string    `json:"rule"`
+	Next       time.Time `json:"next"`
+}
+
+// JoinOption descp: Id is the device id, it goes into the TURN username after the expiry and a colon,
+// so the colon is refused as the ice query does
+type JoinOption struct {
+	MeetingId string `form:"meeting_id" binding:"required"`
+	Id        string `form:"id" binding:"required,max=64,excludesall=0x3A"`
+	Nickname  string `form:"nickname" binding:"required"`
+	Passcode  string `form:"passcode"`
+	Resume    string `form:"resume"`     // descp session token to resume in the grace period
+	HostKey   string `form:"host_key"`   // descp issued to the creator by creating the meeting
+	RelayOnly bool   `form:"relay_only"` // descp hide the addresses of the device from its peers behind TURN
+}
+
+type ChatQuery struct {
+	MeetingId string `form:"meeting_id" binding:"required"`
+	DeviceId  string `form:"id"`
+	Token     string `form:"token"`
+	Passcode  string `form:"passcode"`
+	Before    int64  `form:"before" binding:"min=0"`
+	Limit     int    `form:"limit" binding:"min=0,max=100"`
 }
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"volo_meeting/consts"
+	"volo_meeting/internal/hub"
+	"volo_meeting/internal/model"
+	"volo_meeting/internal/usecase/meeting/request"
+	"volo_meeting/lib/callback"
+	error2 "volo_meeting/lib/error"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetChatHistory descp: page the main room chat history backwards by query.Before, the device holding the session token
+// issued on its last join gets its private chats too, others get the public ones only with the passcode of the meeting
+func GetChatHistory(ctx *gin.Context, query *request.ChatQuery) {
+	if query.DeviceId == "" {
+		meeting, err := findMeeting(query.MeetingId)
+		if err != nil {
+			callback.Error(ctx, err)
+			return
+		}
+		// descp nothing proves the caller has been in a meeting without passcode
+		if !meeting.HasPasscode() {
+			callback.Error(ctx, error2.InvalidSession)
+			return
+		}
+		if err = checkPasscode(ctx, meeting, "", ctx.ClientIP(), query.Passcode); err != nil {
+			callback.Error(ctx, err)
+			return
+		}
+	} else if !hub.Global.CheckAttendance(query.MeetingId, query.DeviceId, query.Token) {
+		callback.Error(ctx, error2.InvalidSession)
+		return
+	}
+
+	callback.Final(ctx, func() (any, error) {
+		return findChats(query)
+	})
+}
+
+func findChats(query *request.ChatQuery) ([]*model.Chat, error) {
+	if query.Limit == 0 {
+		query.Limit = consts.ChatHistorySize
+	}
+
+	chats, err := model.FindChats(model.Instance(), query.MeetingId, query.DeviceId, "", query.Before, query.Limit)
+	if err != nil {
+		zap.L().Error("find chats error", zap.Error(err))
+		return nil, error2.New(consts.SqlError, err)
+	}
+
+	return chats, nil
+}
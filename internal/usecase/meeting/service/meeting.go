@@ -3,6 +3,9 @@ package service
 import (
 	"context"
 	"errors"
+	"net/http"
+	"strings"
+	"time"
 	"volo_meeting/consts"
 	"volo_meeting/internal/cache"
 	"volo_meeting/internal/hub"
@@ -16,16 +19,22 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
-func NewMeeting() (*request.MeetingInfo, error) {
-	mMeeting, err := createMeeting()
+// NewMeeting descp: option.HostId is the device id of the creator, who will be the host once joined with the host key
+func NewMeeting(option *request.MeetingOption) (*request.MeetingInfo, error) {
+	mMeeting := newMeetingModel(option)
+	hostKey := newHostKey(option.HostId)
+	mMeeting.SetHostKey(hostKey)
+
+	mMeeting, err := createMeeting(mMeeting, option.Passcode)
 	if err != nil {
 		return nil, err
 	}
 
-	mMeeting.FriendlyId, err = generateFriendlyId(mMeeting.Id)
+	mMeeting.FriendlyId, err = generateFriendlyId(mMeeting.Id, consts.FriendlyIdExpire)
 	if err != nil {
 		return nil, err
 	}
@@ -33,6 +42,42 @@ func NewMeeting() (*request.MeetingInfo, error) {
 	return &request.MeetingInfo{
 		Id:         mMeeting.Id,
 		FriendlyId: mMeeting.FriendlyId,
+		HostKey:    hostKey,
+	}, nil
+}
+
+// ScheduleMeeting descp: the meeting can only be joined from EarlyJoin minutes before ScheduledStart,
+// and will be ended automatically after its duration plus overtime
+func ScheduleMeeting(option *request.ScheduleOption) (*request.MeetingInfo, error) {
+	start := option.ScheduledStart
+	mMeeting := newMeetingModel(&option.MeetingOption)
+	mMeeting.Title = option.Title
+	mMeeting.Description = option.Description
+	mMeeting.ScheduledStart = &start
+	mMeeting.Duration = option.Duration
+	mMeeting.EarlyJoin = option.EarlyJoin
+	hostKey := newHostKey(option.HostId)
+	mMeeting.SetHostKey(hostKey)
+
+	end, _ := mMeeting.ScheduledEnd()
+	if !end.After(time.Now()) {
+		return nil, error2.MissedMeeting
+	}
+
+	mMeeting, err := createMeeting(mMeeting, option.Passcode)
+	if err != nil {
+		return nil, err
+	}
+
+	mMeeting.FriendlyId, err = generateFriendlyId(mMeeting.Id, time.Until(end)+consts.FriendlyIdExpire)
+	if err != nil {
+		return nil, err
+	}
+
+	return &request.MeetingInfo{
+		Id:         mMeeting.Id,
+		FriendlyId: mMeeting.FriendlyId,
+		HostKey:    hostKey,
 	}, nil
 }
 
@@ -57,19 +102,33 @@ func GetMemberList(id string) ([]*request.Device, error) {
 	return devices, error2.New(consts.CacheError, err)
 }
 
-func JoinMeetingRoom(ctx *gin.Context, id string, device *hub.Device) {
-	// descp get meeting id from cache
-	// if len(id) != consts.DefaultMeetingIdSize {
-	// 	if id, err = cache.Get(ctx, id); err != nil {
-	// 		return err
-	// 	}
-	// }
+func JoinMeetingRoom(ctx *gin.Context, option *request.JoinOption, device *hub.Device) {
+	id, err := resolveMeetingId(ctx, option.MeetingId)
+	if err != nil {
+		callback.Error(ctx, err)
+		return
+	}
 
-	err := checkEndedMeeting(id)
+	meeting, err := checkEndedMeeting(id)
+	if err != nil {
+		callback.Error(ctx, err)
+		return
+	}
+	redirect, err := hub.Global.Locate(id)
 	if err != nil {
 		callback.Error(ctx, err)
 		return
 	}
+	if redirect != nil {
+		redirectOwner(ctx, redirect)
+		return
+	}
+	err = checkPasscode(ctx, meeting, device.Id, ctx.ClientIP(), option.Passcode)
+	if err != nil {
+		callback.Error(ctx, err)
+		return
+	}
+	device.Creator = meeting.IsCreator(device.Id, option.HostKey)
 	err = appendDevice(id, device.Id)
 	if err != nil {
 		callback.Error(ctx, err)
@@ -85,13 +144,54 @@ func JoinMeetingRoom(ctx *gin.Context, id string, device *hub.Device) {
 
 	go conn.Listen()
 
-	hub.Global.JoinRoom(id, device, conn)
+	hub.Global.JoinRoom(id, device, conn, option.Resume)
+}
+
+func newMeetingModel(option *request.MeetingOption) *model.Meeting {
+	return &model.Meeting{
+		HostId:          option.HostId,
+		Lobby:           option.Lobby,
+		MaxParticipants: option.MaxParticipants,
+		Codecs:          strings.Join(option.Codecs, ","),
+		MaxBitrate:      option.MaxBitrate,
+		AudioOnly:       option.AudioOnly,
+	}
+}
+
+// newHostKey descp: empty if the meeting has no creator
+func newHostKey(hostId string) string {
+	if hostId == "" {
+		return ""
+	}
+	return id.Must()
+}
+
+// redirectOwner descp: a websocket client gets consts.Redirect, others get 307 to the owner node
+func redirectOwner(ctx *gin.Context, redirect *hub.Redirect) {
+	redirect.Url = strings.TrimSuffix(redirect.Url, "/") + ctx.Request.URL.RequestURI()
+	if !websocket.IsWebSocketUpgrade(ctx.Request) {
+		ctx.Redirect(http.StatusTemporaryRedirect, redirect.Url)
+		return
+	}
+
+	socket, err := ws.Upgrade(ctx.Writer, ctx.Request)
+	if err != nil {
+		callback.Error(ctx, err)
+		return
+	}
+	hub.SendRedirect(ws.NewConn(socket), redirect)
 }
 
 // createMeeting create a meeting and retry 3 times if failed
-func createMeeting() (*model.Meeting, error) {
+func createMeeting(mMeeting *model.Meeting, passcode string) (*model.Meeting, error) {
+	if err := mMeeting.SetPasscode(passcode); err != nil {
+		return nil, error2.New(consts.SeverError, err)
+	}
+	return insertMeeting(mMeeting)
+}
+
+func insertMeeting(mMeeting *model.Meeting) (*model.Meeting, error) {
 	var err error
-	mMeeting := &model.Meeting{}
 	for i := 0; i < 3; i++ {
 		mMeeting.Id, err = id.GetMeetingId()
 		err = mMeeting.Create(model.Instance())
@@ -104,8 +204,8 @@ func createMeeting() (*model.Meeting, error) {
 	return nil, error2.New(consts.SeverError, err)
 }
 
-// generateFriendlyId generate a friendly id for meeting and redis.set nx 30 days
-func generateFriendlyId(meetingId string) (string, error) {
+// generateFriendlyId generate a friendly id for meeting and redis.set nx, 0 expiration means never expire
+func generateFriendlyId(meetingId string, expiration time.Duration) (string, error) {
 	var (
 		ok         bool
 		err        error
@@ -118,7 +218,7 @@ func generateFriendlyId(meetingId string) (string, error) {
 			zap.L().Error("generate friendly id error", zap.Error(err))
 			continue
 		}
-		ok, err = cache.SetNX(context.TODO(), friendlyId, meetingId, consts.FriendlyIdExpire)
+		ok, err = cache.SetNX(context.TODO(), friendlyId, meetingId, expiration)
 		if !ok {
 			continue
 		}
@@ -150,20 +250,73 @@ func appendDevice(meetingId, deviceId string) error {
 	return error2.New(consts.SqlError, err)
 }
 
-func checkEndedMeeting(id string) error {
+func findMeeting(id string) (*model.Meeting, error) {
 	meeting := &model.Meeting{Id: id}
 	err := meeting.FindById(model.Instance())
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return error2.NotFound("meeting not found, id: " + id)
+			return nil, error2.NotFound("meeting not found, id: " + id)
 		}
 		zap.L().Error("get meeting error", zap.Error(err))
-		return error2.New(consts.SqlError, err)
+		return nil, error2.New(consts.SqlError, err)
+	}
+	return meeting, nil
+}
+
+func checkEndedMeeting(id string) (*model.Meeting, error) {
+	meeting, err := findMeeting(id)
+	if err != nil {
+		return nil, err
 	}
 
 	if meeting.EndTime != nil {
-		return error2.EndedMeeting
+		return nil, error2.EndedMeeting
+	}
+
+	now := time.Now()
+	if !meeting.Opened(now) {
+		return nil, error2.NotOpenedMeeting
+	}
+	if meeting.Missed(now) {
+		return nil, error2.MissedMeeting
+	}
+
+	return meeting, nil
+}
+
+// checkPasscode descp: the device/ip pair is locked out for consts.PasscodeLockout
+// after consts.MaxPasscodeFailures wrong attempts
+func checkPasscode(ctx context.Context, meeting *model.Meeting, deviceId, ip, passcode string) error {
+	if !meeting.HasPasscode() {
+		return nil
+	}
+
+	key := passcodeFailureKey(deviceId, ip)
+	failures, err := cache.GetInt(ctx, key)
+	if err != nil {
+		zap.L().Error("get passcode failures error", zap.Error(err))
+		return error2.New(consts.CacheError, err)
+	}
+	if failures >= consts.MaxPasscodeFailures {
+		return error2.TooManyAttempts
+	}
+
+	if !meeting.CheckPasscode(passcode) {
+		if _, err = cache.Incr(ctx, key, consts.PasscodeLockout); err != nil {
+			zap.L().Error("count passcode failure error", zap.Error(err))
+		}
+		return error2.WrongPasscode
+	}
+
+	if failures > 0 {
+		if err = cache.Del(ctx, key); err != nil {
+			zap.L().Error("reset passcode failures error", zap.Error(err))
+		}
 	}
 
 	return nil
 }
+
+func passcodeFailureKey(deviceId, ip string) string {
+	return "passcode:failure:" + deviceId + ":" + ip
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"volo_meeting/consts"
+	"volo_meeting/internal/cache"
+	"volo_meeting/internal/model"
+	"volo_meeting/internal/usecase/meeting/request"
+	error2 "volo_meeting/lib/error"
+	"volo_meeting/lib/id"
+	"volo_meeting/lib/rrule"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// seriesPrefix descp: the friendly id of a series is cached as seriesPrefix + series id
+const seriesPrefix = "series:"
+
+// NewSeries descp: create a recurring meeting, its friendly id always leads to the current occurrence
+func NewSeries(option *request.SeriesOption) (*request.SeriesInfo, error) {
+	series, err := newSeriesModel(option)
+	if err != nil {
+		return nil, err
+	}
+	hostKey := newHostKey(option.HostId)
+	series.SetHostKey(hostKey)
+
+	next, err := series.Next(time.Now())
+	if err != nil {
+		return nil, error2.EndedSeries
+	}
+
+	for i := 0; i < 3; i++ {
+		series.Id, err = id.GetMeetingId()
+		err = series.Create(model.Instance())
+		if err == nil {
+			break
+		}
+		zap.L().Error("create series error", zap.Error(err))
+	}
+	if err != nil {
+		return nil, error2.New(consts.SqlError, err)
+	}
+
+	// descp an endless series keeps its friendly id forever
+	var expiration time.Duration
+	if set, err := series.Set(); err == nil {
+		if last, ok := set.Last(); ok {
+			expiration = time.Until(last) + time.Duration(series.Duration)*time.Minute + consts.FriendlyIdExpire
+		}
+	}
+	series.FriendlyId, err = generateFriendlyId(seriesPrefix+series.Id, expiration)
+	if err != nil {
+		return nil, err
+	}
+	if err = model.Instance().Model(series).Update("friendly_id", series.FriendlyId).Error; err != nil {
+		zap.L().Error("save series friendly id error", zap.Error(err))
+	}
+
+	return &request.SeriesInfo{
+		Id:         series.Id,
+		FriendlyId: series.FriendlyId,
+		HostKey:    hostKey,
+		Rule:       series.Rule,
+		Next:       next,
+	}, nil
+}
+
+func newSeriesModel(option *request.SeriesOption) (*model.Series, error) {
+	var rule *rrule.Rule
+	switch option.Frequency {
+	case "daily":
+		rule = &rrule.Rule{Freq: rrule.Daily, Interval: 1}
+	case "weekly":
+		rule = &rrule.Rule{Freq: rrule.Weekly, Interval: 1}
+	default:
+		var err error
+		if rule, err = rrule.Parse(option.Rule); err != nil {
+			return nil, error2.New(consts.ParamError, err)
+		}
+	}
+
+	// descp datetime columns keep seconds only
+	start := option.ScheduledStart.Truncate(time.Second)
+	timezone := option.Timezone
+	if timezone == "" {
+		timezone = start.Format("-07:00")
+	}
+
+	series := &model.Series{
+		HostId:          option.HostId,
+		Lobby:           option.Lobby,
+		MaxParticipants: option.MaxParticipants,
+		Title:           option.Title,
+		Description:     option.Description,
+		Rule:            rule.String(),
+		Timezone:        timezone,
+		Start:           start,
+		Duration:        option.Duration,
+		EarlyJoin:       option.EarlyJoin,
+		Codecs:          strings.Join(option.Codecs, ","),
+		MaxBitrate:      option.MaxBitrate,
+		AudioOnly:       option.AudioOnly,
+		Exceptions:      make([]model.SeriesException, 0, len(option.Exceptions)),
+	}
+	if _, err := series.Location(); err != nil {
+		return nil, error2.New(consts.ParamError, err)
+	}
+	for _, e := range option.Exceptions {
+		series.Exceptions = append(series.Exceptions, model.SeriesException{Start: e.Truncate(time.Second)})
+	}
+
+	if err := series.SetPasscode(option.Passcode); err != nil {
+		return nil, error2.New(consts.SeverError, err)
+	}
+
+	return series, nil
+}
+
+// resolveMeetingId descp: a friendly id is turned into the meeting id it stands for,
+// which is the current occurrence for a series
+func resolveMeetingId(ctx context.Context, id string) (string, error) {
+	if len(id) == consts.DefaultMeetingIdSize {
+		return id, nil
+	}
+
+	value, err := cache.GetString(ctx, id)
+	if err != nil {
+		zap.L().Error("get friendly id error", zap.Error(err))
+		return "", error2.New(consts.CacheError, err)
+	}
+	if value == "" {
+		return "", error2.NotFound("meeting not found, id: " + id)
+	}
+
+	seriesId, ok := strings.CutPrefix(value, seriesPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	meeting, err := currentOccurrence(seriesId, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return meeting.Id, nil
+}
+
+// currentOccurrence descp: the running occurrence if any, otherwise the first one not ended yet,
+// its Meeting is created when first asked for
+func currentOccurrence(seriesId string, now time.Time) (*model.Meeting, error) {
+	db := model.Instance()
+	series := &model.Series{Id: seriesId}
+	if err := series.FindById(db); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, error2.NotFound("series not found, id: " + seriesId)
+		}
+		zap.L().Error("get series error", zap.Error(err))
+		return nil, error2.New(consts.SqlError, err)
+	}
+
+	meeting, err := series.FindRunning(db)
+	if err == nil {
+		return meeting, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		zap.L().Error("get running occurrence error", zap.Error(err))
+		return nil, error2.New(consts.SqlError, err)
+	}
+
+	start, err := series.Next(now)
+	if err != nil {
+		if errors.Is(err, model.ErrSeriesOver) {
+			return nil, error2.EndedSeries
+		}
+		return nil, error2.New(consts.SeverError, err)
+	}
+
+	meeting, err = series.FindOccurrence(db, start)
+	if err == nil {
+		return meeting, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		zap.L().Error("get occurrence error", zap.Error(err))
+		return nil, error2.New(consts.SqlError, err)
+	}
+
+	meeting, err = insertMeeting(series.Occurrence(start))
+	if err != nil {
+		// descp the occurrence may be created by another join at the same time
+		if existing, findErr := series.FindOccurrence(db, start); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return meeting, nil
+}
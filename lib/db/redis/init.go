@@ -10,6 +10,14 @@ var rdb *redis.Client
 
 type Z = redis.Z
 
+type PubSub = redis.PubSub
+
+type Script = redis.Script
+
+var NewScript = redis.NewScript
+
+var Nil = redis.Nil
+
 func Init() {
 	rdb = redis.NewClient(&redis.Options{
 		Addr:     viper.GetString("redis.addr"),
@@ -11,6 +11,18 @@ var (
 	InvalidTypeAssert   = New(consts.CacheError, errors.New("type assert error"))
 	InvalidClosedSocket = New(consts.WSError, errors.New("channel has been closed"))
 	EndedMeeting        = New(consts.MeetingError, errors.New("meeting has been ended"))
+	NoPermission        = New(consts.PermissionDenied, errors.New("permission denied"))
+	DeniedByHost        = New(consts.MeetingError, errors.New("denied by host"))
+	FullRoom            = New(consts.MeetingError, errors.New("meeting has reached max participants"))
+	LockedRoom          = New(consts.MeetingError, errors.New("meeting has been locked"))
+	FloorTaken          = New(consts.MeetingError, errors.New("screen-share floor has been taken"))
+	NotOpenedMeeting    = New(consts.NotOpenedError, errors.New("meeting is not open for joining yet"))
+	MissedMeeting       = New(consts.MissedError, errors.New("meeting has passed its scheduled time"))
+	EndedSeries         = New(consts.EndedSeriesError, errors.New("meeting series has no more occurrence"))
+	WrongPasscode       = New(consts.AuthError, errors.New("passcode is wrong"))
+	TooManyAttempts     = New(consts.Forbidden, errors.New("too many wrong passcode attempts"))
+	InvalidSession      = New(consts.AuthError, errors.New("session token is invalid"))
+	TakenId             = New(consts.AuthError, errors.New("device id is in use, resume it with its session token"))
 )
 
 func NotFound(msg string) error {
@@ -0,0 +1,95 @@
+package ice
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var ErrMalformed = errors.New("malformed candidate")
+
+// candidate types of RFC 8445
+const (
+	HostType  = "host"
+	SrflxType = "srflx"
+	PrflxType = "prflx"
+	RelayType = "relay"
+)
+
+// Candidate descp: the candidate-attribute of RFC 8839, such as
+// candidate:842163049 1 udp 1677729535 203.0.113.5 54321 typ srflx raddr 10.0.0.5 rport 54321 generation 0
+type Candidate struct {
+	Foundation string
+	Component  string
+	Transport  string
+	Priority   string
+	Address    string // descp an ip or an mDNS name like xxx.local
+	Port       int
+	Type       string
+	Extensions []string // descp the name value pairs after the type, such as raddr and rport
+	prefix     string
+}
+
+// ParseCandidate descp: the a= and candidate: prefixes are optional and kept by String
+func ParseCandidate(raw string) (*Candidate, error) {
+	prefix := ""
+	value := raw
+	if v, ok := strings.CutPrefix(value, "a="); ok {
+		prefix, value = "a=", v
+	}
+	if v, ok := strings.CutPrefix(value, "candidate:"); ok {
+		prefix, value = prefix+"candidate:", v
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) < 8 || fields[6] != "typ" || len(fields)%2 != 0 {
+		return nil, fmt.Errorf("%w: %q", ErrMalformed, raw)
+	}
+	port, err := strconv.Atoi(fields[5])
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("%w: invalid port %q", ErrMalformed, fields[5])
+	}
+
+	return &Candidate{
+		Foundation: fields[0],
+		Component:  fields[1],
+		Transport:  fields[2],
+		Priority:   fields[3],
+		Address:    fields[4],
+		Port:       port,
+		Type:       fields[7],
+		Extensions: fields[8:],
+		prefix:     prefix,
+	}, nil
+}
+
+func (c *Candidate) String() string {
+	fields := []string{c.Foundation, c.Component, c.Transport, c.Priority, c.Address, strconv.Itoa(c.Port), "typ", c.Type}
+	return c.prefix + strings.Join(append(fields, c.Extensions...), " ")
+}
+
+// IP descp: nil for an mDNS name
+func (c *Candidate) IP() net.IP {
+	return net.ParseIP(c.Address)
+}
+
+// Extension descp: the value of the named extension, such as raddr
+func (c *Candidate) Extension(name string) (string, bool) {
+	for i := 0; i+1 < len(c.Extensions); i += 2 {
+		if c.Extensions[i] == name {
+			return c.Extensions[i+1], true
+		}
+	}
+	return "", false
+}
+
+func (c *Candidate) setExtension(name, value string) {
+	for i := 0; i+1 < len(c.Extensions); i += 2 {
+		if c.Extensions[i] == name {
+			c.Extensions[i+1] = value
+			return
+		}
+	}
+}
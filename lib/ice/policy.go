@@ -0,0 +1,150 @@
+package ice
+
+import (
+	"fmt"
+	"net"
+)
+
+// Policy descp: Apply may rewrite the candidate, false means it is dropped
+type Policy interface {
+	Name() string
+	Apply(c *Candidate) bool
+}
+
+// StripHost descp: drop the host candidates, so that the local addresses are not exposed to the peers
+type StripHost struct{}
+
+func (StripHost) Name() string {
+	return "strip_host"
+}
+
+func (StripHost) Apply(c *Candidate) bool {
+	return c.Type != HostType
+}
+
+// RelayOnly descp: keep the relay candidates only, so that the peers see the address of TURN server only
+type RelayOnly struct{}
+
+func (RelayOnly) Name() string {
+	return "relay_only"
+}
+
+func (RelayOnly) Apply(c *Candidate) bool {
+	return c.Type == RelayType
+}
+
+// Blocklist descp: drop the candidates whose address is in one of the ranges, mDNS names are kept
+type Blocklist []*net.IPNet
+
+func NewBlocklist(cidrs []string) (Blocklist, error) {
+	blocklist := make(Blocklist, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		blocklist = append(blocklist, ipNet)
+	}
+	return blocklist, nil
+}
+
+func (Blocklist) Name() string {
+	return "blocklist"
+}
+
+func (b Blocklist) Apply(c *Candidate) bool {
+	ip := c.IP()
+	if ip == nil {
+		return true
+	}
+	for _, ipNet := range b {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NAT1To1 descp: rewrite the private addresses to the public ones they are mapped to 1:1, never drops.
+// the related address of a srflx or relay candidate is rewritten as well
+type NAT1To1 map[string]string
+
+func NewNAT1To1(mapping map[string]string) (NAT1To1, error) {
+	nat := make(NAT1To1, len(mapping))
+	for private, public := range mapping {
+		privateIP, publicIP := net.ParseIP(private), net.ParseIP(public)
+		if privateIP == nil || publicIP == nil {
+			return nil, fmt.Errorf("invalid 1:1 nat %v -> %v", private, public)
+		}
+		nat[privateIP.String()] = publicIP.String()
+	}
+	return nat, nil
+}
+
+func (NAT1To1) Name() string {
+	return "nat_1to1"
+}
+
+func (n NAT1To1) Apply(c *Candidate) bool {
+	if public, ok := n.public(c.Address); ok {
+		c.Address = public
+		stats.rewritten.Add(1)
+	}
+	if raddr, ok := c.Extension("raddr"); ok {
+		if public, ok := n.public(raddr); ok {
+			c.setExtension("raddr", public)
+		}
+	}
+	return true
+}
+
+func (n NAT1To1) public(address string) (string, bool) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", false
+	}
+	public, ok := n[ip.String()]
+	return public, ok
+}
+
+// Chain descp: the policies applied in order, the first one dropping the candidate is counted
+type Chain []Policy
+
+// Filter descp: apply the chain to a candidate string, the empty one marks the end of candidates and is kept.
+// a malformed candidate is dropped unless the chain is empty
+func (c Chain) Filter(raw string) (string, bool) {
+	if raw == "" || len(c) == 0 {
+		return raw, true
+	}
+
+	candidate, err := ParseCandidate(raw)
+	if err != nil {
+		countDrop(malformed)
+		return "", false
+	}
+	if name, keep := c.apply(candidate); !keep {
+		countDrop(name)
+		return "", false
+	}
+	return candidate.String(), true
+}
+
+// FilterAddress descp: apply the chain to a bare address such as the one of an SDP c= line,
+// which is taken as a host candidate. the drops are not counted
+func (c Chain) FilterAddress(address string) (string, bool) {
+	candidate := &Candidate{Foundation: "0", Component: "1", Transport: "udp", Priority: "0", Address: address, Port: 9, Type: HostType}
+	if _, keep := c.apply(candidate); !keep {
+		return "", false
+	}
+	return candidate.Address, true
+}
+
+// apply descp: the name of the policy dropping the candidate, if any
+func (c Chain) apply(candidate *Candidate) (string, bool) {
+	for _, policy := range c {
+		if !policy.Apply(candidate) {
+			return policy.Name(), false
+		}
+	}
+	return "", true
+}
@@ -0,0 +1,195 @@
+package ice
+
+import "testing"
+
+const (
+	host     = "candidate:1 1 udp 2122260223 10.0.0.5 54321 typ host generation 0"
+	mdnsHost = "candidate:2 1 udp 2122260223 4f1c2e3d-1a2b.local 54321 typ host"
+	srflx    = "candidate:3 1 udp 1686052607 203.0.113.5 54321 typ srflx raddr 10.0.0.5 rport 54321 generation 0"
+	relay    = "candidate:4 1 udp 41885439 198.51.100.7 3478 typ relay raddr 203.0.113.5 rport 54321"
+)
+
+func apply(t *testing.T, policy Policy, raw string) (string, bool) {
+	t.Helper()
+	c, err := ParseCandidate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := policy.Apply(c)
+	return c.String(), keep
+}
+
+func TestParseCandidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "host", raw: host},
+		{name: "srflx", raw: srflx},
+		{name: "sdp attribute", raw: "a=" + relay},
+		{name: "no prefix", raw: "1 1 udp 2122260223 10.0.0.5 54321 typ host"},
+		{name: "tcp", raw: "candidate:5 1 tcp 1518280447 10.0.0.5 9 typ host tcptype active generation 0"},
+		{name: "too short", raw: "candidate:1 1 udp 2122260223 10.0.0.5 54321", wantErr: true},
+		{name: "no typ", raw: "candidate:1 1 udp 2122260223 10.0.0.5 54321 type host", wantErr: true},
+		{name: "bad port", raw: "candidate:1 1 udp 2122260223 10.0.0.5 port typ host", wantErr: true},
+		{name: "odd extension", raw: host + " raddr", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCandidate(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCandidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && c.String() != tt.raw {
+				t.Errorf("String() = %v, want %v", c.String(), tt.raw)
+			}
+		})
+	}
+}
+
+func TestStripHost(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{raw: host, want: false},
+		{raw: mdnsHost, want: false},
+		{raw: srflx, want: true},
+		{raw: relay, want: true},
+	}
+	for _, tt := range tests {
+		if _, got := apply(t, StripHost{}, tt.raw); got != tt.want {
+			t.Errorf("StripHost.Apply(%v) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestRelayOnly(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{raw: host, want: false},
+		{raw: srflx, want: false},
+		{raw: relay, want: true},
+	}
+	for _, tt := range tests {
+		if _, got := apply(t, RelayOnly{}, tt.raw); got != tt.want {
+			t.Errorf("RelayOnly.Apply(%v) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	blocklist, err := NewBlocklist([]string{"10.0.0.0/8", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewBlocklist([]string{"10.0.0.0"}); err == nil {
+		t.Error("NewBlocklist() want error of invalid cidr")
+	}
+
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{raw: host, want: false},
+		{raw: mdnsHost, want: true},
+		{raw: srflx, want: true},
+		{raw: "candidate:6 1 udp 2122262783 2001:db8::1 54321 typ host", want: false},
+	}
+	for _, tt := range tests {
+		if _, got := apply(t, blocklist, tt.raw); got != tt.want {
+			t.Errorf("Blocklist.Apply(%v) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestNAT1To1(t *testing.T) {
+	nat, err := NewNAT1To1(map[string]string{"10.0.0.5": "203.0.113.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewNAT1To1(map[string]string{"10.0.0.5": "public"}); err == nil {
+		t.Error("NewNAT1To1() want error of invalid address")
+	}
+
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: host, want: "candidate:1 1 udp 2122260223 203.0.113.9 54321 typ host generation 0"},
+		{raw: srflx, want: "candidate:3 1 udp 1686052607 203.0.113.5 54321 typ srflx raddr 203.0.113.9 rport 54321 generation 0"},
+		{raw: mdnsHost, want: mdnsHost},
+		{raw: relay, want: relay},
+	}
+	for _, tt := range tests {
+		got, keep := apply(t, nat, tt.raw)
+		if !keep || got != tt.want {
+			t.Errorf("NAT1To1.Apply() = %v %v, want %v", got, keep, tt.want)
+		}
+	}
+}
+
+func TestChain_Filter(t *testing.T) {
+	nat, _ := NewNAT1To1(map[string]string{"10.0.0.5": "203.0.113.9"})
+	chain := Chain{nat, StripHost{}}
+	before := GetStats()
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+		keep bool
+	}{
+		{name: "end of candidates", raw: "", want: "", keep: true},
+		{name: "host", raw: host, keep: false},
+		{name: "srflx", raw: srflx, want: "candidate:3 1 udp 1686052607 203.0.113.5 54321 typ srflx raddr 203.0.113.9 rport 54321 generation 0", keep: true},
+		{name: "malformed", raw: "candidate:1 1 udp", keep: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, keep := chain.Filter(tt.raw)
+			if got != tt.want || keep != tt.keep {
+				t.Errorf("Filter() = %q %v, want %q %v", got, keep, tt.want, tt.keep)
+			}
+		})
+	}
+
+	after := GetStats()
+	if after.Dropped["strip_host"]-before.Dropped["strip_host"] != 1 {
+		t.Errorf("dropped by strip_host = %v, want 1", after.Dropped["strip_host"]-before.Dropped["strip_host"])
+	}
+	if after.Dropped[malformed]-before.Dropped[malformed] != 1 {
+		t.Errorf("dropped as malformed = %v, want 1", after.Dropped[malformed]-before.Dropped[malformed])
+	}
+	if after.Rewritten-before.Rewritten != 1 {
+		t.Errorf("rewritten = %v, want 1", after.Rewritten-before.Rewritten)
+	}
+}
+
+func TestChain_FilterAddress(t *testing.T) {
+	blocklist, _ := NewBlocklist([]string{"192.168.0.0/16"})
+	nat, _ := NewNAT1To1(map[string]string{"10.0.0.5": "203.0.113.9"})
+	chain := Chain{blocklist, nat}
+
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		keep    bool
+	}{
+		{name: "mapped", address: "10.0.0.5", want: "203.0.113.9", keep: true},
+		{name: "blocked", address: "192.168.1.7", keep: false},
+		{name: "untouched", address: "203.0.113.5", want: "203.0.113.5", keep: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, keep := chain.FilterAddress(tt.address)
+			if got != tt.want || keep != tt.keep {
+				t.Errorf("FilterAddress() = %q %v, want %q %v", got, keep, tt.want, tt.keep)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package ice
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const malformed = "malformed"
+
+// Stats descp: process wide counters, Dropped is keyed by the name of the policy dropping the candidates
+type Stats struct {
+	Dropped   map[string]int64 `json:"dropped"`
+	Rewritten int64            `json:"rewritten"`
+}
+
+var stats struct {
+	lock      sync.Mutex
+	dropped   map[string]int64
+	rewritten atomic.Int64
+}
+
+func GetStats() *Stats {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	dropped := make(map[string]int64, len(stats.dropped))
+	for name, count := range stats.dropped {
+		dropped[name] = count
+	}
+	return &Stats{Dropped: dropped, Rewritten: stats.rewritten.Load()}
+}
+
+func countDrop(name string) {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	if stats.dropped == nil {
+		stats.dropped = make(map[string]int64)
+	}
+	stats.dropped[name]++
+}
@@ -0,0 +1,37 @@
+package rendezvous
+
+import "hash/fnv"
+
+// Pick descp: highest random weight hashing, every key goes to the node with the highest weight of it,
+// so removing a node only moves its own keys and adding a node only takes keys from the others.
+// return "" when there is no node
+func Pick(key string, nodes []string) string {
+	var (
+		best   string
+		weight uint64
+	)
+	for _, node := range nodes {
+		if w := score(key, node); best == "" || w > weight || (w == weight && node < best) {
+			best, weight = node, w
+		}
+	}
+	return best
+}
+
+func score(key, node string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(node))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return mix(h.Sum64())
+}
+
+// mix descp: the finalizer of splitmix64, fnv alone spreads similar keys poorly
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,57 @@
+package rendezvous
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPick(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		nodes []string
+		want  string
+	}{
+		{name: "no node", key: "meeting", nodes: nil, want: ""},
+		{name: "single node", key: "meeting", nodes: []string{"a"}, want: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Pick(tt.key, tt.nodes); got != tt.want {
+				t.Errorf("Pick() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPick_Stable(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d"}
+	reversed := []string{"d", "c", "b", "a"}
+	count := make(map[string]int)
+
+	for i := 0; i < 4000; i++ {
+		key := "meeting" + strconv.Itoa(i)
+		owner := Pick(key, nodes)
+		if got := Pick(key, reversed); got != owner {
+			t.Fatalf("Pick() depends on order, %v != %v", got, owner)
+		}
+		count[owner]++
+
+		// descp removing another node never moves the key
+		for j, node := range nodes {
+			if node == owner {
+				continue
+			}
+			rest := append(append([]string{}, nodes[:j]...), nodes[j+1:]...)
+			if got := Pick(key, rest); got != owner {
+				t.Fatalf("Pick() moved %v from %v to %v after removing %v", key, owner, got, node)
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		if count[node] < 800 || count[node] > 1200 {
+			t.Errorf("Pick() is unbalanced: %v", count)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Freq string
+
+const (
+	Daily   Freq = "DAILY"
+	Weekly  Freq = "WEEKLY"
+	Monthly Freq = "MONTHLY"
+)
+
+// maxScan descp: stop looking for the next occurrence after so many periods, in case the rule never matches
+const maxScan = 100000
+
+var (
+	ErrInvalidRule = errors.New("invalid recurrence rule")
+
+	weekdays = map[string]time.Weekday{
+		"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+		"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+	}
+)
+
+// Rule descp: a subset of RFC 5545 RRULE, supports FREQ, INTERVAL, BYDAY, COUNT and UNTIL
+type Rule struct {
+	Freq     Freq
+	Interval int
+	ByDay    []time.Weekday
+	Count    int       // descp 0 means no limit
+	Until    time.Time // descp zero means no limit, inclusive
+}
+
+// Parse descp: parse rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20261231T235959Z", the "RRULE:" prefix is optional
+func Parse(rule string) (*Rule, error) {
+	r := &Rule{Interval: 1}
+
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, part)
+		}
+
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Freq(strings.ToUpper(value))
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		default:
+			err = errors.New("unsupported part")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, part)
+		}
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid until")
+}
+
+func parseByDay(value string) ([]time.Weekday, error) {
+	days := make([]time.Weekday, 0, 7)
+	seen := make(map[time.Weekday]bool, 7)
+	for _, s := range strings.Split(value, ",") {
+		day, ok := weekdays[strings.ToUpper(s)]
+		if !ok {
+			return nil, errors.New("invalid weekday")
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	// descp weeks start on monday, as the default WKST
+	sort.Slice(days, func(i, j int) bool { return (days[i]+6)%7 < (days[j]+6)%7 })
+	return days, nil
+}
+
+func (r *Rule) validate() error {
+	switch r.Freq {
+	case Daily, Weekly, Monthly:
+	default:
+		return fmt.Errorf("%w: unsupported freq %q", ErrInvalidRule, r.Freq)
+	}
+	if r.Interval < 1 || r.Count < 0 {
+		return fmt.Errorf("%w: interval and count must be positive", ErrInvalidRule)
+	}
+	if r.Freq == Monthly && len(r.ByDay) > 0 {
+		return fmt.Errorf("%w: BYDAY is not supported with MONTHLY", ErrInvalidRule)
+	}
+	return nil
+}
+
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			days = append(days, strings.ToUpper(day.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Bounded descp: false means the rule repeats forever
+func (r *Rule) Bounded() bool {
+	return r.Count > 0 || !r.Until.IsZero()
+}
+
+// Iter descp: return a generator of the occurrences from start in order, start itself is the first one
+// only if it matches the rule. ok is false when there is no more occurrence
+func (r *Rule) Iter(start time.Time) func() (t time.Time, ok bool) {
+	var (
+		period  int
+		pending []time.Time
+		emitted int
+	)
+
+	return func() (time.Time, bool) {
+		for len(pending) == 0 {
+			if period >= maxScan {
+				return time.Time{}, false
+			}
+			pending = r.expand(start, period)
+			period++
+		}
+
+		t := pending[0]
+		pending = pending[1:]
+		if (r.Count > 0 && emitted >= r.Count) || (!r.Until.IsZero() && t.After(r.Until)) {
+			period = maxScan
+			pending = nil
+			return time.Time{}, false
+		}
+		emitted++
+		return t, true
+	}
+}
+
+// expand descp: the occurrences in the period-th interval since start, sorted and not before start
+func (r *Rule) expand(start time.Time, period int) []time.Time {
+	var candidates []time.Time
+	switch r.Freq {
+	case Daily:
+		t := start.AddDate(0, 0, period*r.Interval)
+		if r.matchDay(t.Weekday()) {
+			candidates = append(candidates, t)
+		}
+	case Weekly:
+		monday := start.AddDate(0, 0, -int((start.Weekday()+6)%7)+period*r.Interval*7)
+		if len(r.ByDay) == 0 {
+			candidates = append(candidates, start.AddDate(0, 0, period*r.Interval*7))
+			break
+		}
+		for _, day := range r.ByDay {
+			candidates = append(candidates, monday.AddDate(0, 0, int((day+6)%7)))
+		}
+	case Monthly:
+		t := start.AddDate(0, period*r.Interval, 0)
+		// descp skip the months without this day, such as the 31st
+		if t.Day() == start.Day() {
+			candidates = append(candidates, t)
+		}
+	}
+
+	result := candidates[:0]
+	for _, t := range candidates {
+		if !t.Before(start) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func (r *Rule) matchDay(day time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Set descp: occurrences of Rule from Start, except the ones start at Exceptions
+type Set struct {
+	Rule       *Rule
+	Start      time.Time
+	Exceptions []time.Time
+}
+
+// After descp: the first occurrence strictly after t, ok is false when there is no more occurrence
+func (s *Set) After(t time.Time) (time.Time, bool) {
+	next := s.Rule.Iter(s.Start)
+	for {
+		o, ok := next()
+		if !ok {
+			return time.Time{}, false
+		}
+		if o.After(t) && !s.excepted(o) {
+			return o, true
+		}
+	}
+}
+
+// Last descp: the last occurrence, ok is false when the rule repeats forever or has no occurrence
+func (s *Set) Last() (last time.Time, ok bool) {
+	if !s.Rule.Bounded() {
+		return time.Time{}, false
+	}
+	next := s.Rule.Iter(s.Start)
+	for o, more := next(); more; o, more = next() {
+		if !s.excepted(o) {
+			last, ok = o, true
+		}
+	}
+	return last, ok
+}
+
+func (s *Set) excepted(t time.Time) bool {
+	for _, e := range s.Exceptions {
+		if e.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
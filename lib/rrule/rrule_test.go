@@ -0,0 +1,124 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    string
+		wantErr bool
+	}{
+		{"daily", "FREQ=DAILY", "FREQ=DAILY", false},
+		{"prefix", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR,MO", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", false},
+		{"until date", "FREQ=DAILY;UNTIL=20261231", "FREQ=DAILY;UNTIL=20261231T235959Z", false},
+		{"count", "FREQ=MONTHLY;COUNT=3", "FREQ=MONTHLY;COUNT=3", false},
+		{"no freq", "INTERVAL=2", "", true},
+		{"yearly", "FREQ=YEARLY", "", true},
+		{"bad day", "FREQ=WEEKLY;BYDAY=XX", "", true},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0", "", true},
+		{"unknown part", "FREQ=DAILY;BYHOUR=9", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Errorf("Parse() error = %v, want ErrInvalidRule", err)
+				}
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("Parse() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRule_Iter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  []time.Time
+	}{
+		{"daily count", "FREQ=DAILY;COUNT=3", date("2026-10-19 09:00"),
+			[]time.Time{date("2026-10-19 09:00"), date("2026-10-20 09:00"), date("2026-10-21 09:00")}},
+		{"weekdays", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=3", date("2026-10-22 09:00"),
+			[]time.Time{date("2026-10-22 09:00"), date("2026-10-23 09:00"), date("2026-10-26 09:00")}},
+		{"weekly by day", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=4", date("2026-10-21 10:00"),
+			[]time.Time{date("2026-10-21 10:00"), date("2026-11-02 10:00"), date("2026-11-04 10:00"), date("2026-11-16 10:00")}},
+		{"weekly until", "FREQ=WEEKLY;UNTIL=20261102T090000Z", date("2026-10-19 09:00"),
+			[]time.Time{date("2026-10-19 09:00"), date("2026-10-26 09:00"), date("2026-11-02 09:00")}},
+		{"monthly skips short months", "FREQ=MONTHLY;COUNT=3", date("2027-01-31 09:00"),
+			[]time.Time{date("2027-01-31 09:00"), date("2027-03-31 09:00"), date("2027-05-31 09:00")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := rule.Iter(tt.start)
+			for i, want := range tt.want {
+				got, ok := next()
+				if !ok || !got.Equal(want) {
+					t.Fatalf("occurrence %d = %v %v, want %v", i, got, ok, want)
+				}
+			}
+			if got, ok := next(); ok {
+				t.Errorf("unexpected occurrence %v", got)
+			}
+		})
+	}
+}
+
+func TestSet_After(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY;COUNT=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &Set{
+		Rule:       rule,
+		Start:      date("2026-10-19 09:00"),
+		Exceptions: []time.Time{date("2026-10-20 09:00"), date("2026-10-23 09:00")},
+	}
+
+	tests := []struct {
+		name   string
+		after  time.Time
+		want   time.Time
+		wantOk bool
+	}{
+		{"before start", date("2026-10-01 00:00"), date("2026-10-19 09:00"), true},
+		{"skip exception", date("2026-10-19 09:00"), date("2026-10-21 09:00"), true},
+		{"last is excepted", date("2026-10-22 09:00"), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := set.After(tt.after)
+			if ok != tt.wantOk || !got.Equal(tt.want) {
+				t.Errorf("After() = %v %v, want %v %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	if last, ok := set.Last(); !ok || !last.Equal(date("2026-10-22 09:00")) {
+		t.Errorf("Last() = %v %v", last, ok)
+	}
+}
@@ -0,0 +1,89 @@
+package sdp
+
+import "strings"
+
+const (
+	Audio = "audio"
+	Video = "video"
+)
+
+// codecKinds descp: the media kind of the well-known codecs, which decides the kinds Policy.Codecs apply to
+var codecKinds = map[string]string{
+	"opus": Audio, "pcmu": Audio, "pcma": Audio, "g722": Audio, "isac": Audio, "ilbc": Audio,
+	"vp8": Video, "vp9": Video, "h264": Video, "h265": Video, "av1": Video,
+}
+
+// Policy descp: the rewriting of SDP, zero values mean no change.
+// Codecs are the allowed codec names of audio and video in the order of preference, such as opus, vp8, h264,
+// a kind without any of its well-known codecs listed is left as offered.
+// MaxBitrate caps the video sections in kbps
+type Policy struct {
+	Codecs     []string
+	MaxBitrate int
+	AudioOnly  bool
+}
+
+func (p *Policy) IsZero() bool {
+	return len(p.Codecs) == 0 && p.MaxBitrate <= 0 && !p.AudioOnly
+}
+
+// Apply descp: rewrite the session in place, the disabled sections are removed from a=group:BUNDLE
+func (p *Policy) Apply(s *Session) {
+	disabled := make(map[string]bool)
+	for _, m := range s.Media {
+		if m.Kind != Audio && m.Kind != Video || m.Port == 0 {
+			continue
+		}
+
+		if p.AudioOnly && m.Kind == Video {
+			m.Disable()
+		} else if p.lists(m.Kind) {
+			m.FilterCodecs(p.Codecs)
+		}
+
+		if m.Port == 0 {
+			disabled[m.mid()] = true
+		} else if p.MaxBitrate > 0 && m.Kind == Video {
+			m.SetBitrate(p.MaxBitrate)
+		}
+	}
+
+	if len(disabled) > 0 {
+		s.ungroup(disabled)
+	}
+}
+
+// lists descp: whether Codecs name any well-known codec of kind
+func (p *Policy) lists(kind string) bool {
+	for _, name := range p.Codecs {
+		if codecKinds[strings.ToLower(name)] == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Media) mid() string {
+	for _, line := range m.Lines {
+		if mid, ok := strings.CutPrefix(line, "a=mid:"); ok {
+			return mid
+		}
+	}
+	return ""
+}
+
+func (s *Session) ungroup(mids map[string]bool) {
+	for i, line := range s.Lines {
+		value, ok := strings.CutPrefix(line, "a=group:BUNDLE")
+		if !ok {
+			continue
+		}
+		fields := []string{"a=group:BUNDLE"}
+		for _, mid := range strings.Fields(value) {
+			if !mids[mid] {
+				fields = append(fields, mid)
+			}
+		}
+		s.Lines[i] = strings.Join(fields, " ")
+	}
+}
@@ -0,0 +1,264 @@
+package sdp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrMalformed = errors.New("malformed sdp")
+
+// Session descp: an SDP split into the session section and its media sections, lines are kept as they are
+type Session struct {
+	Lines []string
+	Media []*Media
+}
+
+// Media descp: a media section, Lines[0] is the m= line
+type Media struct {
+	Kind    string // descp audio, video or application
+	Port    int
+	Proto   string
+	Formats []string
+	Lines   []string
+}
+
+// Parse descp: the lines must be in type=value form, beginning with v=0 and having o=, s= and t= lines
+func Parse(raw string) (*Session, error) {
+	raw = strings.TrimRight(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	if raw == "" {
+		return nil, fmt.Errorf("%w: empty", ErrMalformed)
+	}
+
+	session := &Session{}
+	var current *Media
+	for i, line := range strings.Split(raw, "\n") {
+		if len(line) < 2 || line[1] != '=' || line[0] < 'a' || line[0] > 'z' {
+			return nil, fmt.Errorf("%w: line %d %q", ErrMalformed, i+1, line)
+		}
+		if i == 0 && line != "v=0" {
+			return nil, fmt.Errorf("%w: must begin with v=0", ErrMalformed)
+		}
+
+		if line[0] == 'm' {
+			media, err := parseMedia(line)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d %v", ErrMalformed, i+1, err)
+			}
+			session.Media = append(session.Media, media)
+			current = media
+			continue
+		}
+
+		if current == nil {
+			session.Lines = append(session.Lines, line)
+		} else {
+			current.Lines = append(current.Lines, line)
+		}
+	}
+
+	for _, t := range []string{"o=", "s=", "t="} {
+		if session.find(t) < 0 {
+			return nil, fmt.Errorf("%w: missing %v line", ErrMalformed, t)
+		}
+	}
+	return session, nil
+}
+
+func parseMedia(line string) (*Media, error) {
+	fields := strings.Fields(line[2:])
+	if len(fields) < 4 {
+		return nil, errors.New("m= line needs media, port, proto and formats")
+	}
+	port, err := strconv.Atoi(strings.SplitN(fields[1], "/", 2)[0])
+	if err != nil {
+		return nil, errors.New("invalid port")
+	}
+	return &Media{Kind: fields[0], Port: port, Proto: fields[2], Formats: fields[3:], Lines: []string{line}}, nil
+}
+
+func (s *Session) find(prefix string) int {
+	for i, line := range s.Lines {
+		if strings.HasPrefix(line, prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Rewrite descp: rewrite the lines with the prefix in the session section and every media section,
+// fn returns false to drop the line
+func (s *Session) Rewrite(prefix string, fn func(line string) (string, bool)) {
+	s.Lines = rewrite(s.Lines, prefix, fn)
+	for _, m := range s.Media {
+		m.Lines = append(m.Lines[:1], rewrite(m.Lines[1:], prefix, fn)...)
+	}
+}
+
+func rewrite(lines []string, prefix string, fn func(line string) (string, bool)) []string {
+	rewritten := lines[:0]
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			var keep bool
+			if line, keep = fn(line); !keep {
+				continue
+			}
+		}
+		rewritten = append(rewritten, line)
+	}
+	return rewritten
+}
+
+func (s *Session) String() string {
+	var b strings.Builder
+	for _, line := range s.Lines {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	for _, m := range s.Media {
+		m.Lines[0] = m.mLine()
+		for _, line := range m.Lines {
+			b.WriteString(line)
+			b.WriteString("\r\n")
+		}
+	}
+	return b.String()
+}
+
+func (m *Media) mLine() string {
+	fields := strings.Fields(m.Lines[0][2:])
+	fields[1] = strconv.Itoa(m.Port) + strings.TrimPrefix(fields[1], strings.SplitN(fields[1], "/", 2)[0])
+	return "m=" + strings.Join(append(fields[:3:3], m.Formats...), " ")
+}
+
+// staticCodecs descp: the static payload types of RFC 3551 which may come without a=rtpmap
+var staticCodecs = map[string]string{"0": "pcmu", "8": "pcma", "9": "g722", "13": "cn", "18": "g729"}
+
+// Codecs descp: the codec name of every payload type by a=rtpmap, such as 111 -> opus
+func (m *Media) Codecs() map[string]string {
+	codecs := make(map[string]string)
+	for _, pt := range m.Formats {
+		if name, ok := staticCodecs[pt]; ok {
+			codecs[pt] = name
+		}
+	}
+	for _, line := range m.Lines {
+		if value, ok := strings.CutPrefix(line, "a=rtpmap:"); ok {
+			pt, encoding, _ := strings.Cut(value, " ")
+			name, _, _ := strings.Cut(encoding, "/")
+			codecs[pt] = strings.ToLower(name)
+		}
+	}
+	return codecs
+}
+
+// apt descp: the payload type each rtx payload type repairs, by a=fmtp:<pt> apt=<pt>
+func (m *Media) apt() map[string]string {
+	apt := make(map[string]string)
+	for _, line := range m.Lines {
+		if value, ok := strings.CutPrefix(line, "a=fmtp:"); ok {
+			pt, params, _ := strings.Cut(value, " ")
+			for _, param := range strings.Split(params, ";") {
+				if target, ok := strings.CutPrefix(strings.TrimSpace(param), "apt="); ok {
+					apt[pt] = target
+				}
+			}
+		}
+	}
+	return apt
+}
+
+// FilterCodecs descp: keep the payload types whose codec is in allowed, ordered as allowed, and the rtx of them.
+// other helpers such as red and ulpfec have to be allowed explicitly, the section is disabled if nothing is left
+func (m *Media) FilterCodecs(allowed []string) {
+	codecs := m.Codecs()
+	if len(codecs) == 0 {
+		return
+	}
+	apt := m.apt()
+
+	rank := make(map[string]int, len(allowed))
+	for i, name := range allowed {
+		if _, ok := rank[strings.ToLower(name)]; !ok {
+			rank[strings.ToLower(name)] = i
+		}
+	}
+
+	kept := make(map[string]bool)
+	formats := make([]string, 0, len(m.Formats))
+	for _, pt := range m.Formats {
+		if _, ok := rank[codecs[pt]]; ok {
+			kept[pt] = true
+			formats = append(formats, pt)
+		}
+	}
+	// descp stable, so the payload types of the same codec keep the order of the sender
+	sort.SliceStable(formats, func(i, j int) bool {
+		return rank[codecs[formats[i]]] < rank[codecs[formats[j]]]
+	})
+	if len(formats) == 0 {
+		m.Disable()
+		return
+	}
+
+	for _, pt := range m.Formats {
+		if codecs[pt] == "rtx" && !kept[pt] && kept[apt[pt]] {
+			kept[pt] = true
+			formats = append(formats, pt)
+		}
+	}
+
+	lines := []string{m.Lines[0]}
+	for _, line := range m.Lines[1:] {
+		if pt, ok := payloadOf(line); ok && !kept[pt] {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	m.Lines = lines
+	m.Formats = formats
+}
+
+// payloadOf descp: the payload type a=rtpmap, a=fmtp and a=rtcp-fb lines are about
+func payloadOf(line string) (string, bool) {
+	for _, prefix := range []string{"a=rtpmap:", "a=fmtp:", "a=rtcp-fb:"} {
+		if value, ok := strings.CutPrefix(line, prefix); ok {
+			pt, _, _ := strings.Cut(value, " ")
+			return pt, pt != "*"
+		}
+	}
+	return "", false
+}
+
+// SetBitrate descp: replace the b= lines by b=AS in kbps and b=TIAS in bps, which go after the i= and c= lines
+func (m *Media) SetBitrate(kbps int) {
+	lines := make([]string, 0, len(m.Lines)+2)
+	for _, line := range m.Lines {
+		if !strings.HasPrefix(line, "b=") {
+			lines = append(lines, line)
+		}
+	}
+
+	at := 1
+	for at < len(lines) && (strings.HasPrefix(lines[at], "i=") || strings.HasPrefix(lines[at], "c=")) {
+		at++
+	}
+	bandwidth := []string{"b=AS:" + strconv.Itoa(kbps), "b=TIAS:" + strconv.Itoa(kbps*1000)}
+	m.Lines = append(lines[:at], append(bandwidth, lines[at:]...)...)
+}
+
+// Disable descp: reject the section by port 0 and stop the stream in both directions
+func (m *Media) Disable() {
+	m.Port = 0
+	lines := m.Lines[:0]
+	for _, line := range m.Lines {
+		switch line {
+		case "a=sendrecv", "a=sendonly", "a=recvonly", "a=inactive":
+			continue
+		}
+		lines = append(lines, line)
+	}
+	m.Lines = append(lines, "a=inactive")
+}
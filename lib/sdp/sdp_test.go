@@ -0,0 +1,139 @@
+package sdp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const offer = "v=0\r\n" +
+	"o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"a=group:BUNDLE 0 1\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111 0 8\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:0\r\n" +
+	"a=sendrecv\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"a=rtcp-fb:111 transport-cc\r\n" +
+	"a=fmtp:111 minptime=10;useinbandfec=1\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=rtpmap:8 PCMA/8000\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96 97 102 103\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"b=AS:2000\r\n" +
+	"a=mid:1\r\n" +
+	"a=sendrecv\r\n" +
+	"a=rtpmap:96 VP8/90000\r\n" +
+	"a=rtcp-fb:96 nack\r\n" +
+	"a=rtpmap:97 rtx/90000\r\n" +
+	"a=fmtp:97 apt=96\r\n" +
+	"a=rtpmap:102 H264/90000\r\n" +
+	"a=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f\r\n" +
+	"a=rtpmap:103 rtx/90000\r\n" +
+	"a=fmtp:103 apt=102\r\n"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "offer", raw: offer},
+		{name: "lf only", raw: strings.ReplaceAll(offer, "\r\n", "\n")},
+		{name: "empty", raw: "", wantErr: true},
+		{name: "no version", raw: strings.TrimPrefix(offer, "v=0\r\n"), wantErr: true},
+		{name: "no origin", raw: strings.Replace(offer, "o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n", "", 1), wantErr: true},
+		{name: "bad line", raw: offer + "hello\r\n", wantErr: true},
+		{name: "bad media", raw: offer + "m=video nine UDP/TLS/RTP/SAVPF 96\r\n", wantErr: true},
+		{name: "no format", raw: offer + "m=video 9 UDP/TLS/RTP/SAVPF\r\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrMalformed) {
+					t.Errorf("Parse() error = %v, want ErrMalformed", err)
+				}
+				return
+			}
+			if got := s.String(); got != offer {
+				t.Errorf("String() = %q, want %q", got, offer)
+			}
+		})
+	}
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "codec order",
+			policy: Policy{Codecs: []string{"opus", "H264", "VP8"}},
+			want: []string{
+				"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n",
+				"m=video 9 UDP/TLS/RTP/SAVPF 102 96 97 103\r\n",
+				"a=group:BUNDLE 0 1\r\n",
+			},
+			notWant: []string{"a=rtpmap:0 PCMU/8000", "a=rtpmap:8 PCMA/8000"},
+		},
+		{
+			name:    "drop codec with its rtx",
+			policy:  Policy{Codecs: []string{"pcma", "vp8"}},
+			want:    []string{"m=audio 9 UDP/TLS/RTP/SAVPF 8\r\n", "m=video 9 UDP/TLS/RTP/SAVPF 96 97\r\n"},
+			notWant: []string{"a=rtpmap:102", "a=fmtp:103", "a=rtcp-fb:111"},
+		},
+		{
+			name:    "video only list",
+			policy:  Policy{Codecs: []string{"vp8"}},
+			want:    []string{"m=audio 9 UDP/TLS/RTP/SAVPF 111 0 8\r\n", "m=video 9 UDP/TLS/RTP/SAVPF 96 97\r\n", "a=group:BUNDLE 0 1\r\n"},
+			notWant: []string{"a=rtpmap:102"},
+		},
+		{
+			name:    "no video codec left",
+			policy:  Policy{Codecs: []string{"opus", "av1"}},
+			want:    []string{"m=video 0 UDP/TLS/RTP/SAVPF", "a=inactive\r\n", "a=group:BUNDLE 0\r\n"},
+			notWant: []string{"a=mid:1\r\na=sendrecv"},
+		},
+		{
+			name:    "max bitrate",
+			policy:  Policy{MaxBitrate: 500},
+			want:    []string{"c=IN IP4 0.0.0.0\r\nb=AS:500\r\nb=TIAS:500000\r\na=mid:1\r\n"},
+			notWant: []string{"b=AS:2000", "a=mid:0\r\nb=AS"},
+		},
+		{
+			name:    "audio only",
+			policy:  Policy{AudioOnly: true, MaxBitrate: 500},
+			want:    []string{"m=audio 9 UDP/TLS/RTP/SAVPF 111 0 8\r\n", "m=video 0 UDP/TLS/RTP/SAVPF", "a=group:BUNDLE 0\r\n"},
+			notWant: []string{"b=AS:500"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(offer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.policy.Apply(s)
+			got := s.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Apply() = %q, want %q", got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("Apply() = %q, not want %q", got, notWant)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package stun
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"net"
+)
+
+// RFC 5389
+const (
+	headerSize  = 20
+	magicCookie = 0x2112A442
+	fingerprint = 0x5354554e // descp xor-ed with the crc32 of FINGERPRINT
+
+	BindingRequest  = 0x0001
+	BindingResponse = 0x0101
+
+	AttrMappedAddress    = 0x0001
+	AttrXorMappedAddress = 0x0020
+	AttrSoftware         = 0x8022
+	AttrFingerprint      = 0x8028
+
+	familyIPv4 = 0x01
+	familyIPv6 = 0x02
+)
+
+var ErrMalformed = errors.New("malformed stun message")
+
+// Message descp: Attributes keep the order they are in, and their values without padding
+type Message struct {
+	Type          uint16
+	TransactionId [12]byte
+	Attributes    []Attribute
+}
+
+type Attribute struct {
+	Type  uint16
+	Value []byte
+}
+
+// Parse descp: the FINGERPRINT is checked if there is one, MESSAGE-INTEGRITY is not
+func Parse(b []byte) (*Message, error) {
+	if len(b) < headerSize || b[0]&0xc0 != 0 || binary.BigEndian.Uint32(b[4:8]) != magicCookie {
+		return nil, ErrMalformed
+	}
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if length%4 != 0 || headerSize+length != len(b) {
+		return nil, ErrMalformed
+	}
+
+	m := &Message{Type: binary.BigEndian.Uint16(b[0:2])}
+	copy(m.TransactionId[:], b[8:20])
+
+	for offset := headerSize; offset < len(b); {
+		if offset+4 > len(b) {
+			return nil, ErrMalformed
+		}
+		attrType := binary.BigEndian.Uint16(b[offset : offset+2])
+		attrLength := int(binary.BigEndian.Uint16(b[offset+2 : offset+4]))
+		end := offset + 4 + attrLength
+		if end > len(b) {
+			return nil, ErrMalformed
+		}
+
+		if attrType == AttrFingerprint {
+			if attrLength != 4 || end != len(b) || binary.BigEndian.Uint32(b[offset+4:end]) != checksum(b[:offset]) {
+				return nil, ErrMalformed
+			}
+		}
+		m.Attributes = append(m.Attributes, Attribute{Type: attrType, Value: b[offset+4 : end]})
+		offset = end + padding(attrLength)
+	}
+	return m, nil
+}
+
+// Marshal descp: a FINGERPRINT is appended after the attributes
+func (m *Message) Marshal() []byte {
+	b := make([]byte, headerSize, 128)
+	binary.BigEndian.PutUint16(b[0:2], m.Type)
+	binary.BigEndian.PutUint32(b[4:8], magicCookie)
+	copy(b[8:20], m.TransactionId[:])
+
+	for _, attr := range m.Attributes {
+		b = appendAttribute(b, attr.Type, attr.Value)
+	}
+
+	// descp the length covers FINGERPRINT when its crc32 is computed
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)-headerSize+8))
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, checksum(b))
+	return appendAttribute(b, AttrFingerprint, value)
+}
+
+func appendAttribute(b []byte, attrType uint16, value []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, attrType)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	b = append(b, make([]byte, padding(len(value)))...)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)-headerSize))
+	return b
+}
+
+func padding(length int) int {
+	return (4 - length%4) % 4
+}
+
+func checksum(b []byte) uint32 {
+	return crc32.ChecksumIEEE(b) ^ fingerprint
+}
+
+// Get descp: the value of the first attribute of the type
+func (m *Message) Get(attrType uint16) ([]byte, bool) {
+	for _, attr := range m.Attributes {
+		if attr.Type == attrType {
+			return attr.Value, true
+		}
+	}
+	return nil, false
+}
+
+// XorAddress descp: the value of XOR-MAPPED-ADDRESS for addr
+func (m *Message) XorAddress(addr *net.UDPAddr) []byte {
+	ip := addr.IP.To4()
+	family := byte(familyIPv4)
+	if ip == nil {
+		ip = addr.IP.To16()
+		family = familyIPv6
+	}
+
+	key := m.xorKey()
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:4], uint16(addr.Port)^uint16(magicCookie>>16))
+	for i := range ip {
+		value[4+i] = ip[i] ^ key[i]
+	}
+	return value
+}
+
+// ParseXorAddress descp: the reverse of XorAddress
+func (m *Message) ParseXorAddress(value []byte) (*net.UDPAddr, error) {
+	if len(value) < 4 || value[1] == familyIPv4 && len(value) != 8 || value[1] == familyIPv6 && len(value) != 20 {
+		return nil, ErrMalformed
+	}
+
+	key := m.xorKey()
+	ip := make(net.IP, len(value)-4)
+	for i := range ip {
+		ip[i] = value[4+i] ^ key[i]
+	}
+	port := binary.BigEndian.Uint16(value[2:4]) ^ uint16(magicCookie>>16)
+	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// xorKey descp: the magic cookie followed by the transaction id
+func (m *Message) xorKey() []byte {
+	key := binary.BigEndian.AppendUint32(make([]byte, 0, 16), magicCookie)
+	return append(key, m.TransactionId[:]...)
+}
@@ -0,0 +1,73 @@
+package stun
+
+import (
+	"errors"
+	"net"
+
+	"go.uber.org/zap"
+)
+
+// maxMessageSize descp: a binding request without credentials is far smaller, larger ones are truncated and dropped
+const maxMessageSize = 1500
+
+// Server descp: answers the binding requests over udp, so that the clients learn their server-reflexive address
+type Server struct {
+	conn *net.UDPConn
+}
+
+func Listen(addr string) (*Server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{conn: conn}, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Serve descp: block until Close
+func (s *Server) Serve() error {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			zap.L().Error("stun read error", zap.Error(err))
+			continue
+		}
+
+		response, ok := Respond(buf[:n], from)
+		if !ok {
+			continue
+		}
+		if _, err = s.conn.WriteToUDP(response, from); err != nil {
+			zap.L().Debug("stun write error", zap.String("to", from.String()), zap.Error(err))
+		}
+	}
+}
+
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+// Respond descp: the binding response to a binding request from addr, ok is false if nothing should be sent back.
+// the other messages and the malformed ones are dropped silently, as the attributes of the request are not needed,
+// the unknown comprehension-required ones don't get 420 either
+func Respond(b []byte, from *net.UDPAddr) (response []byte, ok bool) {
+	request, err := Parse(b)
+	if err != nil || request.Type != BindingRequest {
+		return nil, false
+	}
+
+	message := &Message{Type: BindingResponse, TransactionId: request.TransactionId}
+	message.Attributes = []Attribute{{Type: AttrXorMappedAddress, Value: message.XorAddress(from)}}
+	return message.Marshal(), true
+}
@@ -0,0 +1,146 @@
+package stun
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// descp the IPv4 response of RFC 5769 2.2, with SOFTWARE, XOR-MAPPED-ADDRESS, MESSAGE-INTEGRITY and FINGERPRINT
+var rfc5769Response = []byte{
+	0x01, 0x01, 0x00, 0x3c, 0x21, 0x12, 0xa4, 0x42,
+	0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+	0x80, 0x22, 0x00, 0x0b, 0x74, 0x65, 0x73, 0x74, 0x20, 0x76, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x20,
+	0x00, 0x20, 0x00, 0x08, 0x00, 0x01, 0xa1, 0x47, 0xe1, 0x12, 0xa6, 0x43,
+	0x00, 0x08, 0x00, 0x14, 0x2b, 0x91, 0xf5, 0x99, 0xfd, 0x9e, 0x90, 0xc3, 0x8c, 0x74,
+	0x89, 0xf9, 0x2a, 0xf9, 0xba, 0x53, 0xf0, 0x6b, 0xe7, 0xd7,
+	0x80, 0x28, 0x00, 0x04, 0xc0, 0x7d, 0x4c, 0x96,
+}
+
+func bindingRequest() []byte {
+	request := &Message{Type: BindingRequest, TransactionId: [12]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}}
+	return request.Marshal()
+}
+
+func TestParse(t *testing.T) {
+	m, err := Parse(rfc5769Response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != BindingResponse || len(m.Attributes) != 4 {
+		t.Fatalf("Parse() = %+v", m)
+	}
+	if software, _ := m.Get(AttrSoftware); string(software) != "test vector" {
+		t.Errorf("SOFTWARE = %q, want test vector", software)
+	}
+	value, _ := m.Get(AttrXorMappedAddress)
+	addr, err := m.ParseXorAddress(value)
+	if err != nil || addr.String() != "192.0.2.1:32853" {
+		t.Errorf("XOR-MAPPED-ADDRESS = %v, %v, want 192.0.2.1:32853", addr, err)
+	}
+}
+
+func TestParse_Malformed(t *testing.T) {
+	badFingerprint := append([]byte{}, rfc5769Response...)
+	badFingerprint[len(badFingerprint)-1] ^= 0xff
+	badCookie := bindingRequest()
+	badCookie[4] = 0
+	badLength := append(bindingRequest(), 0, 0, 0, 0)
+
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{name: "short", b: rfc5769Response[:12]},
+		{name: "bad fingerprint", b: badFingerprint},
+		{name: "bad cookie", b: badCookie},
+		{name: "bad length", b: badLength},
+		{name: "not stun", b: append([]byte{0x80}, rfc5769Response[1:]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.b); err == nil {
+				t.Errorf("Parse() want error")
+			}
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		name string
+		from *net.UDPAddr
+	}{
+		{name: "ipv4", from: &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 54321}},
+		{name: "ipv6", from: &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := bindingRequest()
+			b, ok := Respond(request, tt.from)
+			if !ok {
+				t.Fatal("Respond() want a response")
+			}
+			response, err := Parse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Type != BindingResponse || !bytes.Equal(response.TransactionId[:], request[8:20]) {
+				t.Errorf("Respond() = %+v", response)
+			}
+			if _, ok = response.Get(AttrFingerprint); !ok {
+				t.Error("Respond() want FINGERPRINT")
+			}
+			value, _ := response.Get(AttrXorMappedAddress)
+			if addr, err := response.ParseXorAddress(value); err != nil || addr.String() != tt.from.String() {
+				t.Errorf("XOR-MAPPED-ADDRESS = %v, %v, want %v", addr, err, tt.from)
+			}
+		})
+	}
+
+	if _, ok := Respond(rfc5769Response, tests[0].from); ok {
+		t.Error("Respond() want no response to a response")
+	}
+}
+
+func TestServer(t *testing.T) {
+	server, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- server.Serve() }()
+
+	conn, err := net.DialUDP("udp", nil, server.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write(bindingRequest()); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := Parse(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _ := response.Get(AttrXorMappedAddress)
+	if addr, err := response.ParseXorAddress(value); err != nil || addr.String() != conn.LocalAddr().String() {
+		t.Errorf("XOR-MAPPED-ADDRESS = %v, %v, want %v", addr, err, conn.LocalAddr())
+	}
+
+	if err = server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err != nil {
+		t.Errorf("Serve() = %v, want nil after Close", err)
+	}
+}
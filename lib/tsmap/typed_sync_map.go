@@ -7,6 +7,7 @@ import (
 type TSMap[K comparable, V any] interface {
 	Get(key K) (V, bool)
 	Set(key K, value V)
+	GetOrSet(key K, value V) (V, bool)
 	Delete(key K)
 	Len() int
 	Range(fn func(key K, value V), exception ...func(key K, value V) bool)
@@ -30,6 +31,17 @@ func (s *tsMap[K, V]) Set(key K, value V) {
 	s.mp[key] = value
 }
 
+// GetOrSet descp: return the existing value and true, otherwise set value and return it with false
+func (s *tsMap[K, V]) GetOrSet(key K, value V) (V, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if v, ok := s.mp[key]; ok {
+		return v, true
+	}
+	s.mp[key] = value
+	return value, false
+}
+
 func (s *tsMap[K, V]) Delete(key K) {
 	s.Lock()
 	defer s.Unlock()
@@ -0,0 +1,89 @@
+package turn
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"time"
+	"volo_meeting/consts"
+
+	"github.com/spf13/viper"
+)
+
+// ICEServer descp: the RTCIceServer of WebRTC
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Servers descp: Expires is in unix seconds, 0 means the servers carry no credential
+type Servers struct {
+	IceServers []*ICEServer `json:"iceServers"`
+	Expires    int64        `json:"expires"`
+}
+
+// Credential descp: the TURN REST API scheme coturn accepts with use-auth-secret,
+// username is the expiry timestamp and user joined by colon, password is base64 of HMAC-SHA1 of username by secret
+func Credential(secret, user string, expires time.Time) (username, password string) {
+	username = strconv.FormatInt(expires.Unix(), 10)
+	if user != "" {
+		username += ":" + user
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// TTL descp: turn.ttl in seconds, fall back to consts.DefaultTurnTTL
+func TTL() time.Duration {
+	if ttl := viper.GetInt("turn.ttl"); ttl > 0 {
+		return time.Duration(ttl) * time.Second
+	}
+	return consts.DefaultTurnTTL
+}
+
+// Issue descp: the STUN and TURN servers of config, the TURN ones get the credential of user if turn.secret is set.
+// the built-in STUN server is listed by stun.advertise, nil means no server is configured
+func Issue(user string) *Servers {
+	return issue(user, time.Now())
+}
+
+func issue(user string, now time.Time) *Servers {
+	servers := &Servers{IceServers: make([]*ICEServer, 0, 2)}
+	urls := append([]string{}, viper.GetStringSlice("turn.stun_urls")...)
+	if advertise := viper.GetString("stun.advertise"); viper.GetBool("stun.enabled") && advertise != "" {
+		urls = append(urls, advertise)
+	}
+	if len(urls) > 0 {
+		servers.IceServers = append(servers.IceServers, &ICEServer{URLs: urls})
+	}
+
+	if urls := viper.GetStringSlice("turn.turn_urls"); len(urls) > 0 {
+		if secret := viper.GetString("turn.secret"); secret != "" {
+			expires := now.Add(TTL())
+			username, password := Credential(secret, user, expires)
+			servers.IceServers = append(servers.IceServers, &ICEServer{URLs: urls, Username: username, Credential: password})
+			servers.Expires = expires.Unix()
+		}
+	}
+
+	if len(servers.IceServers) == 0 {
+		return nil
+	}
+	return servers
+}
+
+// RefreshIn descp: how long until the credentials should be refreshed, before they expire by consts.TurnRefreshAhead
+// or at half of their lifetime if it is too short. ok is false if there is nothing to refresh
+func (s *Servers) RefreshIn(now time.Time) (d time.Duration, ok bool) {
+	if s == nil || s.Expires == 0 {
+		return 0, false
+	}
+	left := time.Unix(s.Expires, 0).Sub(now)
+	if left > 2*consts.TurnRefreshAhead {
+		return left - consts.TurnRefreshAhead, true
+	}
+	return left / 2, true
+}
@@ -0,0 +1,89 @@
+package turn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestCredential(t *testing.T) {
+	expires := time.Unix(1700000000, 0)
+	tests := []struct {
+		name         string
+		secret       string
+		user         string
+		wantUsername string
+		wantPassword string
+	}{
+		// descp same as: echo -n "1700000000:alice" | openssl dgst -binary -sha1 -hmac secret | base64
+		{name: "with user", secret: "secret", user: "alice", wantUsername: "1700000000:alice", wantPassword: "d8soP47RbdIKLDUOpnJPVQyq5Ts="},
+		{name: "without user", secret: "secret", user: "", wantUsername: "1700000000", wantPassword: "WGw37+g43pfwVUmrc9tgArn/juE="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, password := Credential(tt.secret, tt.user, expires)
+			if username != tt.wantUsername || password != tt.wantPassword {
+				t.Errorf("Credential() = %v, %v, want %v, %v", username, password, tt.wantUsername, tt.wantPassword)
+			}
+		})
+	}
+}
+
+func TestIssue(t *testing.T) {
+	defer viper.Reset()
+	now := time.Unix(1700000000, 0)
+
+	if got := issue("alice", now); got != nil {
+		t.Errorf("issue() = %v, want nil without servers", got)
+	}
+
+	viper.Set("turn.stun_urls", []string{"stun:stun.example.com:3478"})
+	viper.Set("turn.turn_urls", []string{"turn:turn.example.com:3478?transport=udp"})
+	got := issue("alice", now)
+	if len(got.IceServers) != 1 || got.Expires != 0 {
+		t.Errorf("issue() = %+v, want the stun server only without secret", got)
+	}
+
+	viper.Set("turn.secret", "secret")
+	viper.Set("turn.ttl", 600)
+	got = issue("alice", now)
+	if len(got.IceServers) != 2 || got.Expires != now.Unix()+600 {
+		t.Fatalf("issue() = %+v, want stun and turn servers expiring in 600s", got)
+	}
+	if turn := got.IceServers[1]; turn.Username != "1700000600:alice" || turn.Credential == "" {
+		t.Errorf("issue() turn server = %+v", turn)
+	}
+
+	viper.Set("stun.advertise", "stun:meet.example.com:3478")
+	if got = issue("alice", now); len(got.IceServers[0].URLs) != 1 {
+		t.Errorf("issue() stun server = %+v, want the built-in one not listed while disabled", got.IceServers[0])
+	}
+	viper.Set("stun.enabled", true)
+	if got = issue("alice", now); len(got.IceServers[0].URLs) != 2 || got.IceServers[0].URLs[1] != "stun:meet.example.com:3478" {
+		t.Errorf("issue() stun server = %+v, want the built-in one listed", got.IceServers[0])
+	}
+}
+
+func TestServers_RefreshIn(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		servers *Servers
+		want    time.Duration
+		wantOk  bool
+	}{
+		{name: "nil", servers: nil, wantOk: false},
+		{name: "no credential", servers: &Servers{}, wantOk: false},
+		{name: "long lifetime", servers: &Servers{Expires: now.Add(time.Hour).Unix()}, want: 55 * time.Minute, wantOk: true},
+		{name: "short lifetime", servers: &Servers{Expires: now.Add(4 * time.Minute).Unix()}, want: 2 * time.Minute, wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.servers.RefreshIn(now)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("RefreshIn() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package ws
 
 import (
 	"fmt"
+	"sync"
 	"time"
 	"volo_meeting/consts"
 	error2 "volo_meeting/lib/error"
@@ -12,67 +13,84 @@ import (
 	"go.uber.org/zap"
 )
 
+// Conn descp: only the writer goroutine writes to socket, Send just puts the message into the bounded queue.
+// keepalive: the writer pings every pingInterval, and the read deadline is pushed pongWait later by any pong
+// or message, so a silent peer is reaped by the read loop.
+// Close drops the queued messages, CloseAfterFlush lets the writer write them and a close frame first
 type Conn struct {
 	*emission.Emitter
 
-	socket *websocket.Conn
-	timer  *time.Timer
-	closed chan struct{}
+	socket       *websocket.Conn
+	queue        chan []byte
+	policy       SlowConsumerPolicy
+	pingInterval time.Duration
+	pongWait     time.Duration
+	writeWait    time.Duration
+	closed       chan struct{}
+	closeOnce    sync.Once
+	closing      chan struct{} // descp closed by CloseAfterFlush, Send refuses new messages since then
+	closingOnce  sync.Once
 }
 
-func NewConn(socket *websocket.Conn) *Conn {
+func NewConn(socket *websocket.Conn, opts ...Option) *Conn {
+	o := apply(opts...)
 	conn := &Conn{
-		Emitter: emission.NewEmitter(),
-		socket:  socket,
-		closed:  make(chan struct{}),
+		Emitter:      emission.NewEmitter(),
+		socket:       socket,
+		queue:        make(chan []byte, o.queueSize),
+		policy:       o.policy,
+		pingInterval: o.pingInterval,
+		pongWait:     o.pongWait,
+		writeWait:    o.writeWait,
+		closed:       make(chan struct{}),
+		closing:      make(chan struct{}),
 	}
 
 	conn.RecoverWith(func(event, listener interface{}, err error) {
 		zap.L().Error("emitter panic", zap.Error(err), zap.Any("event", event), zap.String("listener", fmt.Sprintf("%v", listener)))
 	})
 
+	stats.conns.Add(1)
+	go conn.write()
+
 	return conn
 }
 
+// Listen descp: the read loop, it returns and emits consts.Close once reading fails or the read deadline passes
 func (conn *Conn) Listen() {
-	// descp keepalive : consts.KeepaliveInterval don't reset timer will close conn
-	conn.timer = time.AfterFunc(consts.KeepaliveInterval, func() {
-		conn.Emit(consts.Close)
+	conn.socket.SetPongHandler(func(string) error {
+		conn.KeepAlive()
+		return nil
 	})
+	conn.KeepAlive()
 
 	for {
-		select {
-		case <-conn.closed:
-			return
-		case <-time.NewTicker(consts.KeepaliveInterval / 2).C: // keepAlive every 10 seconds
-			conn.ping()
-		default:
-			msgType, message, err := conn.socket.ReadMessage()
-			if err != nil {
-				if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-					zap.L().Error("read message error", zap.Error(err))
-				}
-
-				conn.Emit(consts.Close)
-				return
+		_, message, err := conn.socket.ReadMessage()
+		if err != nil {
+			if conn.isClosed() == nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				zap.L().Error("read message error", zap.Error(err))
 			}
 
-			conn.handleMessage(msgType, message)
+			conn.Emit(consts.Close)
+			return
 		}
+
+		conn.KeepAlive()
+		conn.Emit(consts.Message, message)
 	}
 }
 
-func (conn *Conn) handleMessage(messageType int, data []byte) {
-	if messageType == websocket.PongMessage {
-		conn.keepAlive()
-		return
+// KeepAlive descp: push the read deadline pongWait later
+func (conn *Conn) KeepAlive() {
+	err := conn.socket.SetReadDeadline(time.Now().Add(conn.pongWait))
+	if err != nil {
+		zap.L().Debug("set read deadline error", zap.Error(err))
 	}
-
-	conn.Emit(consts.Message, data)
 }
 
+// Send descp: never blocks, a full queue is handled by the slow consumer policy
 func (conn *Conn) Send(data any) {
-	if err := conn.isClosed(); err != nil {
+	if err := conn.isClosing(); err != nil {
 		zap.L().Info("send to closed conn", zap.Any("data", data))
 		return
 	}
@@ -83,23 +101,91 @@ func (conn *Conn) Send(data any) {
 		return
 	}
 
-	err = conn.socket.WriteMessage(websocket.TextMessage, message)
-	if err != nil {
-		zap.L().Error("websocket write message error", zap.Error(err))
-		conn.Emit(consts.Close)
+	select {
+	case conn.queue <- message:
+		stats.queued.Add(1)
+		observeDepth(int64(len(conn.queue)))
+	default:
+		conn.slowConsumer(message)
 	}
 }
 
-func (conn *Conn) keepAlive() {
-	conn.timer.Reset(consts.KeepaliveInterval)
+// slowConsumer descp: the queue depth and the process wide stats are logged on every drop or disconnect,
+// since the dev api exposing them is only served in debug mode
+func (conn *Conn) slowConsumer(message []byte) {
+	depth := zap.Int("depth", len(conn.queue))
+	switch conn.policy {
+	case Drop:
+		stats.dropped.Add(1)
+		zap.L().Info("send queue is full, drop message", depth, zap.Any("stats", GetStats()), zap.ByteString("message", message))
+	default:
+		stats.disconnected.Add(1)
+		zap.L().Info("send queue is full, disconnect slow consumer", depth, zap.Any("stats", GetStats()))
+		// descp Send may be called while the caller holds locks, so close asynchronously
+		go conn.Emit(consts.Close)
+	}
 }
 
-func (conn *Conn) ping() {
-	err := conn.socket.WriteMessage(websocket.PingMessage, []byte("k"))
-	if err != nil {
-		zap.L().Error("websocket write message error", zap.Error(err))
+// write descp: the only goroutine writing to socket, it owns the ping ticker,
+// so ping frames are interleaved between messages. the socket is always closed once it returns
+func (conn *Conn) write() {
+	ticker := time.NewTicker(conn.pingInterval)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+		stats.conns.Add(-1)
+		stats.queued.Add(-int64(len(conn.queue)))
+	}()
+
+	for {
+		select {
+		case <-conn.closed:
+			return
+		case <-conn.closing:
+			conn.flush()
+			return
+		case <-ticker.C:
+			if err := conn.writeMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case message := <-conn.queue:
+			stats.queued.Add(-1)
+			if err := conn.writeMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// flush descp: write the queued messages and a close frame, each of them within writeWait
+func (conn *Conn) flush() {
+	for {
+		select {
+		case message := <-conn.queue:
+			stats.queued.Add(-1)
+			if err := conn.writeMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		default:
+			frame := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			if err := conn.socket.WriteControl(websocket.CloseMessage, frame, time.Now().Add(conn.writeWait)); err != nil {
+				zap.L().Debug("write close frame error", zap.Error(err))
+			}
+			return
+		}
+	}
+}
+
+func (conn *Conn) writeMessage(messageType int, data []byte) error {
+	err := conn.socket.SetWriteDeadline(time.Now().Add(conn.writeWait))
+	if err == nil {
+		err = conn.socket.WriteMessage(messageType, data)
+	}
+	if err != nil && conn.isClosing() == nil {
+		zap.L().Error("websocket write message error", zap.Error(err), zap.Int("type", messageType))
 		conn.Emit(consts.Close)
 	}
+	return err
 }
 
 func (conn *Conn) isClosed() error {
@@ -111,10 +197,31 @@ func (conn *Conn) isClosed() error {
 	}
 }
 
-func (conn *Conn) Close() {
-	close(conn.closed)
-	err := conn.socket.Close()
-	if err != nil {
-		zap.L().Error("Close ws conn error", zap.Error(err))
+// isClosing descp: closed or closing after flush
+func (conn *Conn) isClosing() error {
+	select {
+	case <-conn.closing:
+		return error2.InvalidClosedSocket
+	default:
+		return conn.isClosed()
 	}
 }
+
+// CloseAfterFlush descp: close once the messages sent before are written, safe to be called more than once.
+// use it instead of Close when the peer should get the last messages, such as the error before rejection
+func (conn *Conn) CloseAfterFlush() {
+	conn.closingOnce.Do(func() {
+		close(conn.closing)
+	})
+}
+
+// Close descp: close at once and drop the queued messages, safe to be called more than once
+func (conn *Conn) Close() {
+	conn.closeOnce.Do(func() {
+		close(conn.closed)
+		err := conn.socket.Close()
+		if err != nil {
+			zap.L().Error("Close ws conn error", zap.Error(err))
+		}
+	})
+}
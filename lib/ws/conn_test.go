@@ -0,0 +1,148 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"volo_meeting/consts"
+
+	"github.com/gorilla/websocket"
+)
+
+// newPair descp: return the server side Conn and the client side socket
+func newPair(t *testing.T, opts ...Option) (*Conn, *websocket.Conn) {
+	conns := make(chan *Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		socket, err := Upgrade(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- NewConn(socket, opts...)
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return <-conns, client
+}
+
+func TestConn_Listen(t *testing.T) {
+	tests := []struct {
+		name      string
+		read      bool // descp the client answers ping only while reading
+		wait      time.Duration
+		wantClose bool
+	}{
+		{name: "silent peer is reaped", read: false, wait: time.Second, wantClose: true},
+		{name: "responsive peer stays", read: true, wait: 600 * time.Millisecond, wantClose: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := newPair(t, WithKeepalive(50*time.Millisecond, 150*time.Millisecond))
+			defer conn.Close()
+
+			closed := make(chan struct{}, 1)
+			conn.On(consts.Close, func() {
+				select {
+				case closed <- struct{}{}:
+				default:
+				}
+			})
+			go conn.Listen()
+
+			if tt.read {
+				go func() {
+					for {
+						if _, _, err := client.ReadMessage(); err != nil {
+							return
+						}
+					}
+				}()
+			}
+
+			select {
+			case <-closed:
+				if !tt.wantClose {
+					t.Errorf("Listen() closed, want alive")
+				}
+			case <-time.After(tt.wait):
+				if tt.wantClose {
+					t.Errorf("Listen() alive after %v, want closed", tt.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestConn_Send(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+	}{
+		{name: "in order", messages: []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := newPair(t)
+			defer conn.Close()
+
+			for _, message := range tt.messages {
+				conn.Send(message)
+			}
+
+			_ = client.SetReadDeadline(time.Now().Add(time.Second))
+			for _, want := range tt.messages {
+				_, got, err := client.ReadMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != `"`+want+`"` {
+					t.Errorf("Send() got = %s, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConn_CloseAfterFlush(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+	}{
+		{name: "nothing queued", messages: nil},
+		{name: "last message", messages: []string{"error"}},
+		{name: "many messages", messages: []string{"a", "b", "c", "d", "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := newPair(t)
+
+			for _, message := range tt.messages {
+				conn.Send(message)
+			}
+			conn.CloseAfterFlush()
+			conn.Send("after close")
+
+			_ = client.SetReadDeadline(time.Now().Add(time.Second))
+			for _, want := range tt.messages {
+				_, got, err := client.ReadMessage()
+				if err != nil {
+					t.Fatalf("ReadMessage() error = %v, want %q", err, want)
+				}
+				if string(got) != `"`+want+`"` {
+					t.Errorf("CloseAfterFlush() got = %s, want %q", got, want)
+				}
+			}
+			if _, got, err := client.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("ReadMessage() = %s, %v, want normal closure", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"time"
+	"volo_meeting/consts"
+
+	"github.com/spf13/viper"
+)
+
+// SlowConsumerPolicy descp: what to do when the send queue of a conn is full
+type SlowConsumerPolicy string
+
+const (
+	Drop       SlowConsumerPolicy = "drop"       // descp drop the new message
+	Disconnect SlowConsumerPolicy = "disconnect" // descp close the conn, the member may resume later
+)
+
+type Option func(*options)
+
+type options struct {
+	queueSize    int
+	policy       SlowConsumerPolicy
+	pingInterval time.Duration
+	pongWait     time.Duration
+	writeWait    time.Duration
+}
+
+// defaultOptions descp: read from config, fall back to consts, the intervals in config are in seconds
+func defaultOptions() options {
+	opts := options{
+		queueSize:    viper.GetInt("ws.queue_size"),
+		policy:       SlowConsumerPolicy(viper.GetString("ws.slow_consumer")),
+		pingInterval: seconds("ws.ping_interval", consts.DefaultPingInterval),
+		pongWait:     seconds("ws.pong_wait", consts.DefaultPongWait),
+		writeWait:    seconds("ws.write_wait", consts.DefaultWriteWait),
+	}
+	if opts.queueSize <= 0 {
+		opts.queueSize = consts.DefaultSendQueueSize
+	}
+	if opts.policy != Drop && opts.policy != Disconnect {
+		opts.policy = Disconnect
+	}
+	return opts
+}
+
+func seconds(key string, fallback time.Duration) time.Duration {
+	if value := viper.GetInt(key); value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return fallback
+}
+
+func apply(opts ...Option) options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func WithQueueSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.queueSize = size
+		}
+	}
+}
+
+func WithPolicy(policy SlowConsumerPolicy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithKeepalive descp: pingInterval should be shorter than pongWait
+func WithKeepalive(pingInterval, pongWait time.Duration) Option {
+	return func(o *options) {
+		if pingInterval > 0 {
+			o.pingInterval = pingInterval
+		}
+		if pongWait > 0 {
+			o.pongWait = pongWait
+		}
+	}
+}
+
+func WithWriteWait(writeWait time.Duration) Option {
+	return func(o *options) {
+		if writeWait > 0 {
+			o.writeWait = writeWait
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ws
+
+import "sync/atomic"
+
+// Stats descp: process wide metrics of all conns
+type Stats struct {
+	Conns        int64 `json:"conns"`
+	Queued       int64 `json:"queued"`    // descp messages waiting in all send queues
+	MaxDepth     int64 `json:"max_depth"` // descp the deepest send queue ever seen
+	Dropped      int64 `json:"dropped"`
+	Disconnected int64 `json:"disconnected"` // descp conns closed as slow consumer
+}
+
+var stats struct {
+	conns        atomic.Int64
+	queued       atomic.Int64
+	maxDepth     atomic.Int64
+	dropped      atomic.Int64
+	disconnected atomic.Int64
+}
+
+func GetStats() *Stats {
+	return &Stats{
+		Conns:        stats.conns.Load(),
+		Queued:       stats.queued.Load(),
+		MaxDepth:     stats.maxDepth.Load(),
+		Dropped:      stats.dropped.Load(),
+		Disconnected: stats.disconnected.Load(),
+	}
+}
+
+func observeDepth(depth int64) {
+	for {
+		max := stats.maxDepth.Load()
+		if depth <= max || stats.maxDepth.CompareAndSwap(max, depth) {
+			return
+		}
+	}
+}
@@ -9,9 +9,12 @@ import (
 	"time"
 	"volo_meeting/api"
 	"volo_meeting/config"
+	"volo_meeting/internal/cache"
+	"volo_meeting/internal/hub"
 	"volo_meeting/internal/model"
 	"volo_meeting/lib/db"
 	"volo_meeting/lib/log"
+	"volo_meeting/lib/stun"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -31,7 +34,9 @@ func main() {
 		}
 	}()
 
-	closeServer(srv)
+	stunServer := startStun()
+
+	closeServer(srv, stunServer)
 
 }
 
@@ -40,10 +45,32 @@ func Init() {
 	log.Init()
 
 	db.Init()
+	cache.Init()
 	model.Init()
+	hub.Init()
 }
 
-func closeServer(srv *http.Server) {
+// startStun descp: the built-in STUN server of stun.addr, nil if stun.enabled is false
+func startStun() *stun.Server {
+	if !viper.GetBool("stun.enabled") {
+		return nil
+	}
+
+	stunServer, err := stun.Listen(viper.GetString("stun.addr"))
+	if err != nil {
+		zap.L().Error("Stun Listen", zap.Error(err))
+		panic(err)
+	}
+	go func() {
+		if err := stunServer.Serve(); err != nil {
+			zap.L().Error("Stun Serve", zap.Error(err))
+		}
+	}()
+	zap.L().Info("Stun server started", zap.String("addr", stunServer.Addr().String()))
+	return stunServer
+}
+
+func closeServer(srv *http.Server, stunServer *stun.Server) {
 	defer func(l *zap.Logger) {
 		err := l.Sync()
 		if err != nil {
@@ -61,6 +88,12 @@ func closeServer(srv *http.Server) {
 	if err := srv.Shutdown(ctx); err != nil {
 		zap.L().Error("Server Shutdown", zap.Error(err))
 	}
+	if stunServer != nil {
+		if err := stunServer.Close(); err != nil {
+			zap.L().Error("Stun Close", zap.Error(err))
+		}
+	}
+	hub.Close()
 	zap.L().Info("Server exited")
 
 }